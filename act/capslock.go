@@ -0,0 +1,76 @@
+package act
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Caps Lock 补偿方式
+const (
+	CapsLockShift  = "shift"  // 对受影响的字母反转 shift（默认）
+	CapsLockToggle = "toggle" // 输入前关闭 Caps Lock，输入后恢复
+	CapsLockIgnore = "ignore" // 不做补偿
+)
+
+// ValidateCapsLockMode 校验 Caps Lock 补偿方式，为空时返回默认方式
+func ValidateCapsLockMode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case "":
+		return CapsLockShift, nil
+	case CapsLockShift, CapsLockToggle, CapsLockIgnore:
+		return strings.ToLower(mode), nil
+	default:
+		return "", fmt.Errorf("未知的 caps_lock 模式: %s (可选 shift, toggle, ignore)", mode)
+	}
+}
+
+// compensateCapsLock 在主机 Caps Lock 开启时调整按键序列，使输出大小写与原文一致
+func compensateCapsLock(strokes []KeyStroke, mode string) []KeyStroke {
+	switch mode {
+	case CapsLockToggle:
+		adjusted := make([]KeyStroke, 0, len(strokes)+2)
+		adjusted = append(adjusted, KeyStroke{Key: "capslock"})
+		adjusted = append(adjusted, strokes...)
+		return append(adjusted, KeyStroke{Key: "capslock"})
+
+	case CapsLockShift:
+		adjusted := make([]KeyStroke, len(strokes))
+		for i, stroke := range strokes {
+			if stroke.capsAffected {
+				stroke.Modifiers = toggleShift(stroke.Modifiers)
+			}
+			adjusted[i] = stroke
+		}
+		return adjusted
+
+	default:
+		return strokes
+	}
+}
+
+// toggleShift 有 shift 时去掉，没有时加上
+func toggleShift(modifiers []string) []string {
+	toggled := make([]string, 0, len(modifiers)+1)
+	found := false
+	for _, modifier := range modifiers {
+		if modifier == "shift" {
+			found = true
+			continue
+		}
+		toggled = append(toggled, modifier)
+	}
+	if !found {
+		toggled = append(toggled, "shift")
+	}
+	return toggled
+}
+
+// capsLockActive 查询驱动报告的主机 Caps Lock 状态，驱动不支持或状态未知时 ok 为 false
+func capsLockActive(driver KeyboardDriver) (on bool, ok bool) {
+	reporter, supported := driverCapability[LEDStateReporter](driver)
+	if !supported {
+		return false, false
+	}
+	state, known := reporter.LEDState()
+	return state.CapsLock, known
+}
@@ -0,0 +1,103 @@
+package act
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompensateCapsLock(t *testing.T) {
+	us, _ := GetLayout("us")
+	strokes, _ := us.TextToKeyStrokes("aB1!")
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		// 只反转字母的 shift，数字和符号不受 Caps Lock 影响
+		{CapsLockShift, []string{"shift+a", "b", "1", "shift+1"}},
+		{CapsLockToggle, []string{"capslock", "a", "shift+b", "1", "shift+1", "capslock"}},
+		{CapsLockIgnore, []string{"a", "shift+b", "1", "shift+1"}},
+	}
+	for _, tt := range tests {
+		got := strokeNames(compensateCapsLock(strokes, tt.mode))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v，期望 %v", tt.mode, got, tt.want)
+		}
+	}
+	if got := strokeNames(strokes); !reflect.DeepEqual(got, []string{"a", "shift+b", "1", "shift+1"}) {
+		t.Errorf("compensateCapsLock 修改了原按键序列: %v", got)
+	}
+}
+
+// TestCompensateCapsLockNonLetterKeys 字母不在 a-z 键位上时同样受 Caps Lock 影响
+func TestCompensateCapsLockNonLetterKeys(t *testing.T) {
+	tests := []struct {
+		layout string
+		text   string
+		want   []string
+	}{
+		// 法式布局 m 在 ; 键上，é 与 2 共用一个键，不受 Caps Lock 影响
+		{"fr", "mMé", []string{"shift+;", ";", "2"}},
+		// 德式布局 ü/ö/ä 在 [ ; ' 键上
+		{"de", "üÖä", []string{"shift+[", ";", "shift+'"}},
+	}
+	for _, tt := range tests {
+		layout, _ := GetLayout(tt.layout)
+		strokes, _ := layout.TextToKeyStrokes(tt.text)
+		if got := strokeNames(compensateCapsLock(strokes, CapsLockShift)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: %v，期望 %v", tt.layout, tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestVirtualDriverTypeCapsLockMode 驱动 Type 使用配置的补偿方式
+func TestVirtualDriverTypeCapsLockMode(t *testing.T) {
+	layout, _ := GetLayout("de")
+	for mode, want := range map[string]string{CapsLockShift: "Mü", CapsLockIgnore: "mÜ"} {
+		driver := NewVirtualDriver(layout)
+		driver.SetCapsLockMode(mode)
+		if err := driver.Press("capslock", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := driver.Type("Mü"); err != nil {
+			t.Fatal(err)
+		}
+		if got := driver.RenderedText(); got != want {
+			t.Errorf("%s: 主机看到 %q，期望 %q", mode, got, want)
+		}
+	}
+}
+
+// TestCompensateCapsLockRendered Caps Lock 开启时补偿后的按键在主机上还原为原文
+func TestCompensateCapsLockRendered(t *testing.T) {
+	for _, name := range []string{"us", "de", "fr"} {
+		layout, _ := GetLayout(name)
+		text := "Password1! Äé"
+		strokes, unsupported := layout.TextToKeyStrokes(text)
+		if len(unsupported) > 0 {
+			text = "Password1!"
+			strokes, _ = layout.TextToKeyStrokes(text)
+		}
+		for _, mode := range []string{CapsLockShift, CapsLockToggle} {
+			renderer := NewTextRenderer(layout)
+			renderer.Press("capslock", nil)
+			for _, stroke := range compensateCapsLock(strokes, mode) {
+				renderer.Press(stroke.Key, stroke.Modifiers)
+			}
+			if got := renderer.Text(); got != text {
+				t.Errorf("%s %s: 主机看到 %q，期望 %q", name, mode, got, text)
+			}
+		}
+	}
+}
+
+func TestValidateCapsLockMode(t *testing.T) {
+	for input, want := range map[string]string{"": CapsLockShift, "Toggle": CapsLockToggle, "ignore": CapsLockIgnore} {
+		if got, err := ValidateCapsLockMode(input); err != nil || got != want {
+			t.Errorf("ValidateCapsLockMode(%q) = %q, %v，期望 %q", input, got, err, want)
+		}
+	}
+	if _, err := ValidateCapsLockMode("off"); err == nil {
+		t.Error("ValidateCapsLockMode(\"off\") 未返回错误")
+	}
+}
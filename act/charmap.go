@@ -0,0 +1,27 @@
+package act
+
+import "fmt"
+
+// KeyStroke 一次字符输入对应的按键组合
+type KeyStroke struct {
+	Key       string   `json:"key"`                 // keyMap 中的按键名
+	Modifiers []string `json:"modifiers,omitempty"` // 需要同时按住的修饰键，如 shift
+	Action    string   `json:"action,omitempty"`    // 为空表示按下并释放，down/up 表示只按下或只释放
+
+	capsAffected bool // 输出字符的大小写受 Caps Lock 影响（字母键）
+}
+
+// 按键动作
+const (
+	StrokeDown = "down" // 只按下，不释放
+	StrokeUp   = "up"   // 只释放
+)
+
+// UnsupportedCharsError 文本中存在无法输入的字符
+type UnsupportedCharsError struct {
+	Chars []rune
+}
+
+func (e *UnsupportedCharsError) Error() string {
+	return fmt.Sprintf("无法输入的字符: %q", string(e.Chars))
+}
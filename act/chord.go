@@ -0,0 +1,85 @@
+package act
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChordSeparator 组合键表达式中按键之间的分隔符，如 "ctrl+shift+t"
+const ChordSeparator = "+"
+
+// ChordPresser 能原子地按下组合键的驱动（可选能力）
+type ChordPresser interface {
+	// PressChord 按下组合键的所有按键，持续指定时间后全部释放，期间不会混入其它组合键
+	PressChord(keys []string, duration time.Duration) error
+}
+
+// IsChord 判断按键表达式是否为组合键（小键盘的 "kp+" 是单个按键）
+func IsChord(expr string) bool {
+	return len(expr) > 1 && strings.Contains(expr, ChordSeparator) && expr != "kp+"
+}
+
+// ParseChord 解析组合键表达式，按键名解析为标准名且须被驱动支持，返回按下顺序的按键名
+func ParseChord(expr string, driver KeyboardDriver) ([]string, error) {
+	parts := strings.Split(strings.ToLower(expr), ChordSeparator)
+	var keys []string
+	seen := make(map[string]bool, len(parts))
+	for i := 0; i < len(parts); i++ {
+		part := strings.TrimSpace(parts[i])
+		// "kp+" 中的加号属于按键名
+		if part == "kp" && i+1 < len(parts) && parts[i+1] == "" {
+			part = "kp+"
+			i++
+		}
+		if part == "" {
+			return nil, fmt.Errorf("组合键格式无效: %q", expr)
+		}
+		key := CanonicalKey(part)
+		if !driver.IsKeySupported(key) {
+			return nil, fmt.Errorf("组合键 %s 中不支持的按键: %s", expr, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("组合键 %s 中按键重复: %s", expr, part)
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// PressChord 原子地按下组合键：驱动（或其包装链）实现 ChordPresser 时一次完成按下和释放，
+// 否则逐个按下后逆序释放
+func PressChord(driver KeyboardDriver, keys []string, duration time.Duration) error {
+	if len(keys) == 1 {
+		return driver.Press(keys[0], duration)
+	}
+	if presser, ok := driverCapability[ChordPresser](driver); ok {
+		return presser.PressChord(keys, duration)
+	}
+	return pressChordSequential(driver, keys, duration)
+}
+
+// pressChordSequential 逐个按下组合键中的按键，持续指定时间后逆序释放
+// 用于没有原生组合键支持的驱动，中途失败时释放已按下的按键
+func pressChordSequential(driver KeyboardDriver, keys []string, duration time.Duration) error {
+	var held []string
+	release := func() error {
+		var err error
+		for i := len(held) - 1; i >= 0; i-- {
+			if uerr := driver.KeyUp(held[i]); uerr != nil && err == nil {
+				err = fmt.Errorf("按键 %s 释放失败: %v", held[i], uerr)
+			}
+		}
+		return err
+	}
+	for _, key := range keys {
+		if err := driver.KeyDown(key); err != nil {
+			release()
+			return fmt.Errorf("按键 %s 按下失败: %v", key, err)
+		}
+		held = append(held, key)
+	}
+	time.Sleep(duration)
+	return release()
+}
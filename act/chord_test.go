@@ -0,0 +1,114 @@
+package act
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsChord(t *testing.T) {
+	tests := map[string]bool{
+		"a":            false,
+		"+":            false,
+		"kp+":          false,
+		"ctrl+c":       true,
+		"ctrl+alt+del": true,
+		"ctrl++":       true,
+		"shift+kp+":    true,
+	}
+	for expr, want := range tests {
+		if got := IsChord(expr); got != want {
+			t.Errorf("IsChord(%q) = %v，期望 %v", expr, got, want)
+		}
+	}
+}
+
+func TestParseChord(t *testing.T) {
+	driver := NewVirtualDriver(nil)
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"ctrl+c", []string{"control", "c"}},
+		{"Ctrl+Shift+T", []string{"control", "shift", "t"}},
+		{"ctrl + alt + del", []string{"control", "alt", "delete"}},
+		{"win+l", []string{"gui", "l"}},
+		{"shift+kp+", []string{"shift", "kp+"}},
+		{"kp++kp-", []string{"kp+", "kp-"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseChord(tt.expr, driver)
+		if err != nil {
+			t.Errorf("ParseChord(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseChord(%q) = %v，期望 %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"ctrl+", "+a", "ctrl++a", "ctrl+no_such_key", "ctrl+control+a", "shift+a+A"} {
+		if keys, err := ParseChord(expr, driver); err == nil {
+			t.Errorf("ParseChord(%q) = %v，期望返回错误", expr, keys)
+		}
+	}
+}
+
+// plainDriver 只暴露 KeyboardDriver 接口的方法，没有原生组合键支持
+type plainDriver struct {
+	KeyboardDriver
+}
+
+// failingDriver 按下指定按键时失败
+type failingDriver struct {
+	KeyboardDriver
+	fail string
+}
+
+func (d failingDriver) KeyDown(key string) error {
+	if key == d.fail {
+		return ErrInjectedFault
+	}
+	return d.KeyboardDriver.KeyDown(key)
+}
+
+func TestPressChordSequential(t *testing.T) {
+	virtual := NewVirtualDriver(nil)
+	if err := PressChord(plainDriver{virtual}, []string{"control", "shift", "t"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, event := range virtual.Events(0) {
+		got = append(got, event.Action+" "+event.Key)
+	}
+	want := []string{
+		VirtualDown + " control", VirtualDown + " shift", VirtualDown + " t",
+		VirtualUp + " t", VirtualUp + " shift", VirtualUp + " control",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("事件 %v，期望按顺序按下、逆序释放 %v", got, want)
+	}
+
+	// 中途失败时释放已按下的按键
+	virtual = NewVirtualDriver(nil)
+	if err := pressChordSequential(failingDriver{virtual, "t"}, []string{"control", "shift", "t"}, 0); err == nil {
+		t.Error("按键按下失败时未返回错误")
+	}
+	if pressed := virtual.PressedKeys(); len(pressed) != 0 {
+		t.Errorf("失败后仍按住 %v", pressed)
+	}
+}
+
+func TestPressChordNative(t *testing.T) {
+	virtual := NewVirtualDriver(nil)
+	if err := PressChord(virtual, []string{"shift", "a"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	events := virtual.Events(0)
+	if len(events) != 1 || events[0].Action != VirtualPress || events[0].Key != "shift+a" {
+		t.Errorf("原生组合键事件 %+v，期望一次 press shift+a", events)
+	}
+	if text := virtual.RenderedText(); text != "A" {
+		t.Errorf("主机看到 %q，期望 \"A\"", text)
+	}
+}
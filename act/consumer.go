@@ -0,0 +1,34 @@
+package act
+
+// consumerKeyMap Consumer 页（0x0C）媒体键 usage 映射表
+var consumerKeyMap = map[string]uint16{
+	// 播放控制
+	"media_play": 0xcd, "media_next": 0xb5, "media_prev": 0xb6,
+	"media_stop": 0xb7, "media_eject": 0xb8,
+	// 音量
+	"media_mute": 0xe2, "media_volume_up": 0xe9, "media_volume_down": 0xea,
+	// 亮度
+	"brightness_up": 0x6f, "brightness_down": 0x70,
+	// 浏览器
+	"browser_search": 0x221, "browser_home": 0x223, "browser_back": 0x224,
+	"browser_forward": 0x225, "browser_stop": 0x226, "browser_refresh": 0x227,
+	"browser_bookmarks": 0x22a,
+	// 应用
+	"app_mail": 0x18a, "app_calculator": 0x192, "app_file_browser": 0x194,
+}
+
+// isConsumerKey 判断是否为媒体键
+func isConsumerKey(key string) bool {
+	_, ok := consumerKeyMap[key]
+	return ok
+}
+
+// ConsumerKeyName 返回 Consumer usage 对应的媒体键名
+func ConsumerKeyName(usage uint16) (string, bool) {
+	for name, u := range consumerKeyMap {
+		if u == usage {
+			return name, true
+		}
+	}
+	return "", false
+}
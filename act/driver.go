@@ -26,9 +26,30 @@ type KeyboardDriver interface {
 	GetDriverType() string
 }
 
+// DriverWrapper 包装其它驱动的驱动，可选能力从被包装的驱动上查找
+type DriverWrapper interface {
+	Unwrap() KeyboardDriver
+}
+
+// driverCapability 在驱动及其包装链上查找可选能力
+func driverCapability[T any](driver KeyboardDriver) (T, bool) {
+	for {
+		if capability, ok := driver.(T); ok {
+			return capability, true
+		}
+		wrapper, ok := driver.(DriverWrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		driver = wrapper.Unwrap()
+	}
+}
+
 // DriverType 驱动类型常量
 const (
 	DriverTypeLinuxOTG = "linux_otg"
 	DriverTypeMacOS    = "macos_automation"
 	DriverTypeWindows  = "windows_automation"
+	DriverTypeVirtual  = "virtual" // 内存虚拟驱动，记录事件，不访问硬件
 )
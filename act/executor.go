@@ -0,0 +1,149 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrExecutorBusy 执行队列已满
+var ErrExecutorBusy = errors.New("执行队列已满，请稍后重试")
+
+// executorQueueSize 每个目标最多排队的批量操作数
+const executorQueueSize = 64
+
+// keyBatch 一批需要按顺序执行的按键请求
+type keyBatch struct {
+	name     string
+	requests []KeyRequest
+	fn       func(ctx context.Context) error // 不为 nil 时执行 fn 而不是 requests
+	done     chan error
+}
+
+// SequentialExecutor 按目标顺序执行批量按键
+//
+// 同一目标（同一个驱动）上的批量操作排队依次执行，批内按键严格按顺序、
+// 按各自的持续时间和间隔发送，避免多个 goroutine 并发导致按键乱序。
+// 不同目标各自拥有执行器，互不阻塞。
+type SequentialExecutor struct {
+	target string
+	run    func(KeyRequest) error
+	queue  chan *keyBatch
+	ctx    context.Context
+	wg     sync.WaitGroup
+}
+
+// NewSequentialExecutor 创建目标执行器并启动执行协程，run 负责执行单个按键请求
+func NewSequentialExecutor(ctx context.Context, target string, run func(KeyRequest) error) *SequentialExecutor {
+	e := &SequentialExecutor{
+		target: target,
+		run:    run,
+		queue:  make(chan *keyBatch, executorQueueSize),
+		ctx:    ctx,
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+// Submit 提交一批按键请求，返回的通道在该批执行结束后收到执行结果
+func (e *SequentialExecutor) Submit(name string, requests []KeyRequest) (<-chan error, error) {
+	return e.enqueue(&keyBatch{
+		name:     name,
+		requests: requests,
+		done:     make(chan error, 1),
+	})
+}
+
+// SubmitFunc 提交一个独占执行的函数，与批量按键排队执行，ctx 在执行器关闭时取消
+func (e *SequentialExecutor) SubmitFunc(name string, fn func(ctx context.Context) error) (<-chan error, error) {
+	return e.enqueue(&keyBatch{
+		name: name,
+		fn:   fn,
+		done: make(chan error, 1),
+	})
+}
+
+// enqueue 将批量操作加入队列，队列已满时返回 ErrExecutorBusy
+func (e *SequentialExecutor) enqueue(batch *keyBatch) (<-chan error, error) {
+	select {
+	case e.queue <- batch:
+		return batch.done, nil
+	case <-e.ctx.Done():
+		return nil, e.ctx.Err()
+	default:
+		return nil, ErrExecutorBusy
+	}
+}
+
+// Pending 返回排队中的批量操作数
+func (e *SequentialExecutor) Pending() int {
+	return len(e.queue)
+}
+
+// Wait 等待执行协程退出（ctx 取消后）
+func (e *SequentialExecutor) Wait() {
+	e.wg.Wait()
+}
+
+// loop 依次取出批量操作执行
+func (e *SequentialExecutor) loop() {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case batch := <-e.queue:
+			if batch.fn != nil {
+				batch.done <- batch.fn(e.ctx)
+				continue
+			}
+			batch.done <- e.runBatch(batch)
+		}
+	}
+}
+
+// runBatch 顺序执行一批按键，出错或被取消时停止执行剩余按键并释放批内按住的键
+func (e *SequentialExecutor) runBatch(batch *keyBatch) error {
+	startTime := time.Now()
+	var held []KeyRequest
+	abort := func(i int, err error) error {
+		for j := len(held) - 1; j >= 0; j-- {
+			release := held[j]
+			release.Action = StrokeUp
+			e.run(release)
+		}
+		log.Printf("[EXECUTOR] %s 批量操作 %s 在第 %d/%d 个按键中止: %v",
+			e.target, batch.name, i+1, len(batch.requests), err)
+		return err
+	}
+
+	for i, req := range batch.requests {
+		if err := e.run(req); err != nil {
+			return abort(i, err)
+		}
+		switch req.Action {
+		case StrokeDown:
+			held = append(held, req)
+		case StrokeUp:
+			for j := len(held) - 1; j >= 0; j-- {
+				if held[j].Key == req.Key {
+					held = append(held[:j], held[j+1:]...)
+					break
+				}
+			}
+		}
+		if req.Gap > 0 {
+			select {
+			case <-time.After(req.Gap):
+			case <-e.ctx.Done():
+				return abort(i, e.ctx.Err())
+			}
+		}
+	}
+	log.Printf("[EXECUTOR] %s 批量操作 %s 完成: %d个按键, 耗时 %v",
+		e.target, batch.name, len(batch.requests), time.Since(startTime))
+	return nil
+}
@@ -2,8 +2,11 @@ package act
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"pi-keyboard/hid"
 	"runtime"
+	"time"
 )
 
 // DriverFactory 驱动工厂
@@ -23,13 +26,48 @@ func (f *DriverFactory) CreateDriver(options ...DriverOption) (KeyboardDriver, e
 		option(config)
 	}
 
-	// 如果指定了驱动类型，直接创建
+	var driver KeyboardDriver
+	var err error
 	if config.DriverType != "" {
-		return f.createSpecificDriver(config.DriverType, config)
+		// 如果指定了驱动类型，直接创建
+		driver, err = f.createSpecificDriver(config.DriverType, config)
+	} else {
+		// 自动检测平台
+		driver, err = f.createAutoDetectedDriver(config)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// 自动检测平台
-	return f.createAutoDetectedDriver(config)
+	// 故障注入
+	if config.Faults != nil {
+		log.Printf("[FAULT] %s 驱动已启用故障注入", driver.GetDriverType())
+		driver = NewFaultDriver(driver, *config.Faults)
+	}
+	return driver, nil
+}
+
+// CreateMouseDriver 创建鼠标驱动，目前只支持 Linux OTG
+func (f *DriverFactory) CreateMouseDriver(options ...DriverOption) (MouseDriver, error) {
+	config := &DriverConfig{}
+	for _, option := range options {
+		option(config)
+	}
+
+	driverType := config.DriverType
+	if driverType == "" && runtime.GOOS == "linux" {
+		driverType = DriverTypeLinuxOTG
+	}
+
+	switch driverType {
+	case DriverTypeLinuxOTG:
+		if !config.hidFeatures().Mouse {
+			return nil, fmt.Errorf("HID 特性未启用 %s", hid.FeatureMouse)
+		}
+		return NewLinuxOTGMouseDriver(config), nil
+	default:
+		return nil, fmt.Errorf("驱动类型 %q 不支持鼠标", driverType)
+	}
 }
 
 // createAutoDetectedDriver 自动检测平台并创建驱动
@@ -38,7 +76,7 @@ func (f *DriverFactory) createAutoDetectedDriver(config *DriverConfig) (Keyboard
 	case "linux":
 		// 检查是否有 HID Gadget 支持
 		if f.hasHIDGadgetSupport(config.OutputFile) {
-			return NewLinuxOTGDriver(config.OutputFile), nil
+			return f.newLinuxOTGDriver(config)
 		}
 		return nil, fmt.Errorf("linux 系统未检测到 hid gadget 支持，请确保 /dev/hidg0 存在")
 
@@ -57,7 +95,7 @@ func (f *DriverFactory) createAutoDetectedDriver(config *DriverConfig) (Keyboard
 func (f *DriverFactory) createSpecificDriver(driverType string, config *DriverConfig) (KeyboardDriver, error) {
 	switch driverType {
 	case DriverTypeLinuxOTG:
-		return NewLinuxOTGDriver(config.OutputFile), nil
+		return f.newLinuxOTGDriver(config)
 
 	case DriverTypeMacOS:
 		return NewMacOSDriver(), nil
@@ -65,11 +103,60 @@ func (f *DriverFactory) createSpecificDriver(driverType string, config *DriverCo
 	case DriverTypeWindows:
 		return NewWindowsDriver(), nil
 
+	case DriverTypeVirtual:
+		return f.newVirtualDriver(config)
+
 	default:
 		return nil, fmt.Errorf("未知的驱动类型: %s", driverType)
 	}
 }
 
+// newLinuxOTGDriver 按配置创建 Linux OTG 驱动
+func (f *DriverFactory) newLinuxOTGDriver(config *DriverConfig) (KeyboardDriver, error) {
+	driver := NewLinuxOTGDriverWithConfig(config)
+	if config.Layout != "" {
+		layout, err := GetLayout(config.Layout)
+		if err != nil {
+			return nil, err
+		}
+		driver.SetLayout(layout)
+	}
+	strategy, err := GetUnicodeStrategy(config.UnicodeStrategy)
+	if err != nil {
+		return nil, err
+	}
+	driver.SetUnicodeStrategy(strategy)
+	mode, err := ValidateCapsLockMode(config.CapsLockMode)
+	if err != nil {
+		return nil, err
+	}
+	driver.SetCapsLockMode(mode)
+	return driver, nil
+}
+
+// newVirtualDriver 按配置创建虚拟驱动
+func (f *DriverFactory) newVirtualDriver(config *DriverConfig) (KeyboardDriver, error) {
+	driver := NewVirtualDriver(nil)
+	if config.Layout != "" {
+		layout, err := GetLayout(config.Layout)
+		if err != nil {
+			return nil, err
+		}
+		driver.SetLayout(layout)
+	}
+	strategy, err := GetUnicodeStrategy(config.UnicodeStrategy)
+	if err != nil {
+		return nil, err
+	}
+	driver.SetUnicodeStrategy(strategy)
+	mode, err := ValidateCapsLockMode(config.CapsLockMode)
+	if err != nil {
+		return nil, err
+	}
+	driver.SetCapsLockMode(mode)
+	return driver, nil
+}
+
 // hasHIDGadgetSupport 检查是否有 HID Gadget 支持
 func (f *DriverFactory) hasHIDGadgetSupport(outputFile string) bool {
 	if outputFile == "" {
@@ -108,6 +195,8 @@ func (f *DriverFactory) GetAvailableDrivers() []string {
 		// Windows 暂未实现
 	}
 
+	// 虚拟驱动在所有平台可用
+	drivers = append(drivers, DriverTypeVirtual)
 	return drivers
 }
 
@@ -115,6 +204,32 @@ func (f *DriverFactory) GetAvailableDrivers() []string {
 type DriverConfig struct {
 	DriverType string // 强制指定驱动类型
 	OutputFile string // Linux OTG 输出文件路径
+	FileSink   bool   // 允许输出到普通文件或 FIFO（默认只接受字符设备）
+
+	ConsumerFile string // Linux OTG 媒体键（Consumer Control）输出文件路径，默认按 HID 特性推算（见 DefaultHIDFile）
+	MouseFile    string // Linux OTG 鼠标输出文件路径，默认按 HID 特性推算（见 DefaultHIDFile）
+
+	WriteTimeout time.Duration // HID 报文写入期限，超时返回 ErrHostNotReady（仅对 Linux OTG 有效）
+	Layout       string        // 主机键盘布局名称（仅对 Linux OTG 有效）
+
+	UnicodeStrategy string // 布局无法输入的字符的输入策略（仅对 Linux OTG 有效）
+	CapsLockMode    string // 主机 Caps Lock 开启时 Type 的补偿方式，为空时使用 shift
+
+	HIDFeatures  hid.Features // gadget 的 HID 特性集合，决定报文格式（仅对 Linux OTG 有效），为空时使用默认特性
+	ProtocolFile string       // 外部提供的 SET_PROTOCOL 协议文件（标准 f_hid 不提供），NKRO 模式据此回退到 boot 报文（仅对 Linux OTG 有效）
+
+	UDCRoot string // UDC sysfs 目录，默认 /sys/class/udc（仅对 Linux OTG 有效）
+	UDC     string // 监视的 UDC 名称，为空时使用第一个 UDC（仅对 Linux OTG 有效）
+
+	Faults *FaultPlan // 故障注入计划，非 nil 时用 FaultDriver 包装创建的驱动
+}
+
+// hidFeatures 返回 HID 特性集合，未配置时使用默认特性
+func (c *DriverConfig) hidFeatures() hid.Features {
+	if c.HIDFeatures == (hid.Features{}) {
+		return hid.DefaultFeatures()
+	}
+	return c.HIDFeatures
 }
 
 // DriverOption 驱动配置选项
@@ -133,3 +248,88 @@ func WithOutputFile(outputFile string) DriverOption {
 		config.OutputFile = outputFile
 	}
 }
+
+// WithConsumerFile 指定媒体键输出文件（仅对 Linux OTG 有效）
+func WithConsumerFile(consumerFile string) DriverOption {
+	return func(config *DriverConfig) {
+		config.ConsumerFile = consumerFile
+	}
+}
+
+// WithMouseFile 指定鼠标输出文件（仅对 Linux OTG 有效）
+func WithMouseFile(mouseFile string) DriverOption {
+	return func(config *DriverConfig) {
+		config.MouseFile = mouseFile
+	}
+}
+
+// WithFileSink 允许 Linux OTG 驱动输出到普通文件或 FIFO，用于调试和模拟
+func WithFileSink(enabled bool) DriverOption {
+	return func(config *DriverConfig) {
+		config.FileSink = enabled
+	}
+}
+
+// WithWriteTimeout 指定 HID 报文写入期限（仅对 Linux OTG 有效）
+func WithWriteTimeout(timeout time.Duration) DriverOption {
+	return func(config *DriverConfig) {
+		config.WriteTimeout = timeout
+	}
+}
+
+// WithLayout 指定主机键盘布局（仅对 Linux OTG 有效）
+func WithLayout(layout string) DriverOption {
+	return func(config *DriverConfig) {
+		config.Layout = layout
+	}
+}
+
+// WithUnicodeStrategy 指定布局无法输入的字符的输入策略（仅对 Linux OTG 有效）
+func WithUnicodeStrategy(strategy string) DriverOption {
+	return func(config *DriverConfig) {
+		config.UnicodeStrategy = strategy
+	}
+}
+
+// WithCapsLockMode 指定主机 Caps Lock 开启时驱动 Type 的补偿方式
+func WithCapsLockMode(mode string) DriverOption {
+	return func(config *DriverConfig) {
+		config.CapsLockMode = mode
+	}
+}
+
+// WithHIDFeatures 指定 gadget 的 HID 特性集合，须与 gadget up 使用的特性一致（仅对 Linux OTG 有效）
+func WithHIDFeatures(features hid.Features) DriverOption {
+	return func(config *DriverConfig) {
+		config.HIDFeatures = features
+	}
+}
+
+// WithProtocolFile 指定 HID 协议文件，内容变化时切换 boot/报告协议（仅对 Linux OTG 有效）
+// 标准 f_hid 不向用户态上报 SET_PROTOCOL，该文件须由打过补丁的内核或辅助程序写入
+func WithProtocolFile(path string) DriverOption {
+	return func(config *DriverConfig) {
+		config.ProtocolFile = path
+	}
+}
+
+// WithUDCRoot 指定 UDC sysfs 目录，测试时可指向包含假 state 文件的目录（仅对 Linux OTG 有效）
+func WithUDCRoot(root string) DriverOption {
+	return func(config *DriverConfig) {
+		config.UDCRoot = root
+	}
+}
+
+// WithUDC 指定监视的 UDC 名称（仅对 Linux OTG 有效）
+func WithUDC(udc string) DriverOption {
+	return func(config *DriverConfig) {
+		config.UDC = udc
+	}
+}
+
+// WithFaultInjection 用故障注入装饰器包装驱动，用于测试设备缓慢或失败时 HTTP 层的表现
+func WithFaultInjection(plan FaultPlan) DriverOption {
+	return func(config *DriverConfig) {
+		config.Faults = &plan
+	}
+}
@@ -0,0 +1,358 @@
+package act
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 注入故障的方法名（FaultPlan.Methods 的键）
+const (
+	FaultPress   = "press"
+	FaultKeyDown = "keydown"
+	FaultKeyUp   = "keyup"
+	FaultType    = "type"
+	FaultDefault = "*" // 未单独配置的方法
+)
+
+// ErrInjectedFault 注入的故障
+var ErrInjectedFault = errors.New("注入的故障")
+
+// FaultConfig 单个方法的故障注入配置
+type FaultConfig struct {
+	Latency      time.Duration `json:"latency"`        // 每次调用的固定延迟
+	Jitter       time.Duration `json:"jitter"`         // 额外的随机延迟 [0, Jitter)
+	ErrorRate    float64       `json:"error_rate"`     // 调用直接失败的概率（0-1），不调用被包装的驱动
+	HangRate     float64       `json:"hang_rate"`      // 调用挂起的概率（0-1）
+	HangFor      time.Duration `json:"hang_for"`       // 挂起时长，0 表示挂起到驱动关闭
+	PartialRate  float64       `json:"partial_rate"`   // 部分失败的概率（0-1）：Press 只按下不释放，Type 只输入前一半，KeyDown/KeyUp 执行后仍返回错误
+	HostNotReady bool          `json:"host_not_ready"` // 注入的错误为 ErrHostNotReady（HTTP 504），否则为 ErrInjectedFault（HTTP 500）
+}
+
+// FaultPlan 故障注入计划
+type FaultPlan struct {
+	Methods map[string]FaultConfig `json:"methods"` // 方法名 -> 配置，"*" 为默认配置
+	Seed    int64                  `json:"seed"`    // 随机种子，0 表示使用当前时间
+}
+
+// FaultStats 故障注入统计
+type FaultStats struct {
+	Calls    map[string]int64 `json:"calls"`
+	Errors   map[string]int64 `json:"errors"`
+	Hangs    map[string]int64 `json:"hangs"`
+	Partials map[string]int64 `json:"partials"`
+}
+
+// FaultReporter 可报告故障注入统计的驱动（可选能力）
+type FaultReporter interface {
+	FaultStats() FaultStats
+}
+
+// ParseFaultPlan 解析故障注入描述
+//
+// 格式为分号分隔的 "方法:参数=值,参数=值"，方法为 press、keydown、keyup、type 或 *，例如
+//
+//	press:latency=200ms,error=0.1;type:partial=0.2;*:hang=0.01,hang_for=5s
+//
+// 参数：latency、jitter、error、hang、hang_for、partial、host_not_ready（true/false）、seed（仅 *）
+func ParseFaultPlan(spec string) (FaultPlan, error) {
+	plan := FaultPlan{Methods: make(map[string]FaultConfig)}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		method, params, ok := strings.Cut(entry, ":")
+		if !ok {
+			return plan, fmt.Errorf("故障注入项缺少方法名: %q", entry)
+		}
+		method = strings.ToLower(strings.TrimSpace(method))
+		switch method {
+		case FaultPress, FaultKeyDown, FaultKeyUp, FaultType, FaultDefault:
+		default:
+			return plan, fmt.Errorf("未知的故障注入方法: %s (可选 press, keydown, keyup, type, *)", method)
+		}
+
+		config := plan.Methods[method]
+		for _, param := range strings.Split(params, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok {
+				return plan, fmt.Errorf("故障注入参数格式应为 key=value: %q", param)
+			}
+			var err error
+			switch strings.ToLower(key) {
+			case "latency":
+				config.Latency, err = time.ParseDuration(value)
+			case "jitter":
+				config.Jitter, err = time.ParseDuration(value)
+			case "hang_for":
+				config.HangFor, err = time.ParseDuration(value)
+			case "error":
+				config.ErrorRate, err = parseRate(value)
+			case "hang":
+				config.HangRate, err = parseRate(value)
+			case "partial":
+				config.PartialRate, err = parseRate(value)
+			case "host_not_ready":
+				config.HostNotReady, err = strconv.ParseBool(value)
+			case "seed":
+				plan.Seed, err = strconv.ParseInt(value, 10, 64)
+			default:
+				err = fmt.Errorf("未知参数")
+			}
+			if err != nil {
+				return plan, fmt.Errorf("故障注入参数 %s=%s 无效: %v", key, value, err)
+			}
+		}
+		plan.Methods[method] = config
+	}
+	return plan, nil
+}
+
+// parseRate 解析 0-1 之间的概率
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("概率必须在 0-1 之间")
+	}
+	return rate, nil
+}
+
+// FaultDriver 注入延迟、错误、挂起和部分失败的驱动装饰器，用于测试 HTTP 层在设备异常时的表现
+type FaultDriver struct {
+	inner KeyboardDriver
+	plan  FaultPlan
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats FaultStats
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFaultDriver 用故障注入计划包装驱动
+func NewFaultDriver(inner KeyboardDriver, plan FaultPlan) *FaultDriver {
+	seed := plan.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultDriver{
+		inner: inner,
+		plan:  plan,
+		rand:  rand.New(rand.NewSource(seed)),
+		stats: FaultStats{
+			Calls:    make(map[string]int64),
+			Errors:   make(map[string]int64),
+			Hangs:    make(map[string]int64),
+			Partials: make(map[string]int64),
+		},
+		stop: make(chan struct{}),
+	}
+}
+
+// Unwrap 返回被包装的驱动
+func (d *FaultDriver) Unwrap() KeyboardDriver {
+	return d.inner
+}
+
+// Press 按下并释放按键，部分失败时只按下不释放
+func (d *FaultDriver) Press(key string, duration time.Duration) error {
+	fault, err := d.inject(FaultPress)
+	if err != nil {
+		return err
+	}
+	if fault == faultPartial {
+		if err := d.inner.KeyDown(key); err != nil {
+			return err
+		}
+		return d.faultError(FaultPress, fmt.Sprintf("按键 %s 已按下，释放失败", key))
+	}
+	return d.inner.Press(key, duration)
+}
+
+// PressChord 按下组合键（按 press 注入故障），部分失败时只按下不释放
+func (d *FaultDriver) PressChord(keys []string, duration time.Duration) error {
+	fault, err := d.inject(FaultPress)
+	if err != nil {
+		return err
+	}
+	if fault == faultPartial {
+		for _, key := range keys {
+			if err := d.inner.KeyDown(key); err != nil {
+				return err
+			}
+		}
+		return d.faultError(FaultPress, fmt.Sprintf("组合键 %s 已按下，释放失败", strings.Join(keys, ChordSeparator)))
+	}
+	if presser, ok := driverCapability[ChordPresser](d.inner); ok {
+		return presser.PressChord(keys, duration)
+	}
+	return pressChordSequential(d.inner, keys, duration)
+}
+
+// KeyDown 按下按键，部分失败时按下后仍返回错误
+func (d *FaultDriver) KeyDown(key string) error {
+	return d.passthrough(FaultKeyDown, key, d.inner.KeyDown)
+}
+
+// KeyUp 释放按键，部分失败时释放后仍返回错误
+func (d *FaultDriver) KeyUp(key string) error {
+	return d.passthrough(FaultKeyUp, key, d.inner.KeyUp)
+}
+
+// Type 输入文本，部分失败时只输入前一半
+func (d *FaultDriver) Type(text string) error {
+	fault, err := d.inject(FaultType)
+	if err != nil {
+		return err
+	}
+	if fault == faultPartial {
+		runes := []rune(text)
+		half := len(runes) / 2
+		if err := d.inner.Type(string(runes[:half])); err != nil {
+			return err
+		}
+		return d.faultError(FaultType, fmt.Sprintf("已输入 %d/%d 个字符", half, len(runes)))
+	}
+	return d.inner.Type(text)
+}
+
+// IsKeySupported 检查是否支持指定按键（不注入故障）
+func (d *FaultDriver) IsKeySupported(key string) bool {
+	return d.inner.IsKeySupported(key)
+}
+
+// Close 结束所有挂起的调用并关闭被包装的驱动
+func (d *FaultDriver) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	return d.inner.Close()
+}
+
+// GetDriverType 返回被包装驱动的类型
+func (d *FaultDriver) GetDriverType() string {
+	return d.inner.GetDriverType()
+}
+
+// FaultStats 返回故障注入统计
+func (d *FaultDriver) FaultStats() FaultStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copyMap := func(m map[string]int64) map[string]int64 {
+		c := make(map[string]int64, len(m))
+		for k, v := range m {
+			c[k] = v
+		}
+		return c
+	}
+	return FaultStats{
+		Calls:    copyMap(d.stats.Calls),
+		Errors:   copyMap(d.stats.Errors),
+		Hangs:    copyMap(d.stats.Hangs),
+		Partials: copyMap(d.stats.Partials),
+	}
+}
+
+// 注入结果
+const (
+	faultNone = iota
+	faultPartial
+)
+
+// passthrough 执行 KeyDown/KeyUp，部分失败时执行后仍返回错误
+func (d *FaultDriver) passthrough(method, key string, call func(string) error) error {
+	fault, err := d.inject(method)
+	if err != nil {
+		return err
+	}
+	if err := call(key); err != nil {
+		return err
+	}
+	if fault == faultPartial {
+		return d.faultError(method, fmt.Sprintf("按键 %s 已执行，返回错误", key))
+	}
+	return nil
+}
+
+// inject 按配置注入延迟、挂起和错误，返回是否需要部分失败
+func (d *FaultDriver) inject(method string) (int, error) {
+	config, ok := d.plan.Methods[method]
+	if !ok {
+		config = d.plan.Methods[FaultDefault]
+	}
+
+	d.mu.Lock()
+	d.stats.Calls[method]++
+	delay := config.Latency
+	if config.Jitter > 0 {
+		delay += time.Duration(d.rand.Int63n(int64(config.Jitter)))
+	}
+	hang := d.rand.Float64() < config.HangRate
+	fail := d.rand.Float64() < config.ErrorRate
+	partial := d.rand.Float64() < config.PartialRate
+	d.mu.Unlock()
+
+	if delay > 0 && !d.sleep(delay) {
+		return faultNone, ErrDeviceClosed
+	}
+	if hang {
+		d.count(d.stats.Hangs, method)
+		log.Printf("[FAULT] %s 挂起 %v", method, config.HangFor)
+		if !d.sleep(config.HangFor) {
+			return faultNone, ErrDeviceClosed
+		}
+		return faultNone, d.faultError(method, "挂起后超时")
+	}
+	if fail {
+		return faultNone, d.faultError(method, "调用失败")
+	}
+	if partial {
+		d.count(d.stats.Partials, method)
+		return faultPartial, nil
+	}
+	return faultNone, nil
+}
+
+// sleep 等待指定时间，0 表示等到驱动关闭；驱动关闭时返回 false
+func (d *FaultDriver) sleep(duration time.Duration) bool {
+	if duration <= 0 {
+		<-d.stop
+		return false
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
+
+// faultError 构造注入的错误并计数
+func (d *FaultDriver) faultError(method, detail string) error {
+	d.count(d.stats.Errors, method)
+	config, ok := d.plan.Methods[method]
+	if !ok {
+		config = d.plan.Methods[FaultDefault]
+	}
+	if config.HostNotReady {
+		return fmt.Errorf("%w（%s %s）", ErrHostNotReady, method, detail)
+	}
+	return fmt.Errorf("%w: %s %s", ErrInjectedFault, method, detail)
+}
+
+// count 统计计数
+func (d *FaultDriver) count(counter map[string]int64, method string) {
+	d.mu.Lock()
+	counter[method]++
+	d.mu.Unlock()
+}
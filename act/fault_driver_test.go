@@ -0,0 +1,73 @@
+package act
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFaultPlan(t *testing.T) {
+	plan, err := ParseFaultPlan("press:latency=200ms,error=0.1; TYPE:partial=0.2;*:hang=0.01,hang_for=5s,seed=42,host_not_ready=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := FaultPlan{
+		Methods: map[string]FaultConfig{
+			FaultPress:   {Latency: 200 * time.Millisecond, ErrorRate: 0.1},
+			FaultType:    {PartialRate: 0.2},
+			FaultDefault: {HangRate: 0.01, HangFor: 5 * time.Second, HostNotReady: true},
+		},
+		Seed: 42,
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("ParseFaultPlan = %+v，期望 %+v", plan, want)
+	}
+
+	for _, spec := range []string{
+		"latency=1s",
+		"click:error=0.1",
+		"press:error",
+		"press:error=1.5",
+		"press:latency=fast",
+		"press:retry=3",
+	} {
+		if _, err := ParseFaultPlan(spec); err == nil {
+			t.Errorf("ParseFaultPlan(%q) 未返回错误", spec)
+		}
+	}
+}
+
+func TestFaultDriverCapabilities(t *testing.T) {
+	virtual := NewVirtualDriver(nil)
+	driver := NewFaultDriver(virtual, FaultPlan{Methods: map[string]FaultConfig{
+		FaultPress: {ErrorRate: 1, HostNotReady: true},
+	}})
+	defer driver.Close()
+
+	// 可选能力穿过包装链查找
+	if _, ok := driverCapability[EventRecorder](driver); !ok {
+		t.Error("未能通过 FaultDriver 找到被包装驱动的 EventRecorder")
+	}
+	if _, ok := driverCapability[HostStateReporter](driver); ok {
+		t.Error("虚拟驱动不应提供 HostStateReporter")
+	}
+	reporter, ok := driverCapability[FaultReporter](driver)
+	if !ok {
+		t.Fatal("FaultDriver 未实现 FaultReporter")
+	}
+
+	if err := driver.Press("a", 0); !errors.Is(err, ErrHostNotReady) {
+		t.Errorf("Press 返回 %v，期望 ErrHostNotReady", err)
+	}
+	if err := driver.KeyDown("b"); err != nil {
+		t.Errorf("未配置故障的 KeyDown: %v", err)
+	}
+	stats := reporter.FaultStats()
+	if stats.Calls[FaultPress] != 1 || stats.Errors[FaultPress] != 1 || stats.Calls[FaultKeyDown] != 1 {
+		t.Errorf("故障统计 %+v", stats)
+	}
+	if pressed := virtual.PressedKeys(); !reflect.DeepEqual(pressed, []string{"b"}) {
+		t.Errorf("被包装驱动按住 %v，期望 [b]", pressed)
+	}
+}
@@ -0,0 +1,347 @@
+package act
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"pi-keyboard/hid"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrDeviceClosed HID 设备已关闭
+	ErrDeviceClosed = errors.New("hid 设备已关闭")
+
+	// ErrHostNotReady 主机未在期限内接收报文（休眠、挂起或线缆已拔出）
+	ErrHostNotReady = errors.New("主机未就绪")
+)
+
+// DefaultHIDFile 返回 gadget up 按特性集合创建的 function 对应的设备文件
+// f_hid 按创建顺序分配 /dev/hidgN，创建顺序与 hid.Features.Kinds 一致（系统中只有这一个 HID gadget 时成立）；
+// 特性集合未启用该类型时返回空字符串
+func DefaultHIDFile(features hid.Features, kind string) string {
+	for i, k := range features.Kinds() {
+		if k == kind {
+			return fmt.Sprintf("/dev/hidg%d", i)
+		}
+	}
+	return ""
+}
+
+// DefaultHIDWriteTimeout 默认的单个报文写入期限
+const DefaultHIDWriteTimeout = time.Second
+
+// 重新打开设备的退避时间
+const (
+	hidReopenMinBackoff = 100 * time.Millisecond
+	hidReopenMaxBackoff = 5 * time.Second
+)
+
+// DeviceHealth HID 设备健康状态
+type DeviceHealth struct {
+	Path          string    `json:"path"`
+	Open          bool      `json:"open"`
+	FileSink      bool      `json:"file_sink"`
+	Reopens       int64     `json:"reopens"`
+	Written       int64     `json:"written_reports"`
+	Failed        int64     `json:"failed_reports"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+	NextRetry     time.Time `json:"next_retry,omitempty"`
+}
+
+// DeviceHealthReporter 可报告设备健康状态的驱动（可选能力）
+type DeviceHealthReporter interface {
+	DeviceHealth() DeviceHealth
+}
+
+// hidWrite 一次写入请求
+type hidWrite struct {
+	report []byte
+	done   chan error
+}
+
+// HIDDevice 持久打开的 HID gadget 设备
+//
+// 所有报文由单个写协程写入同一个文件句柄，避免每个报文都 open/close。
+// 主机断开时（ENODEV/ESHUTDOWN）关闭句柄并按退避时间自动重新打开。
+// 默认只接受字符设备，fileSink 为 true 时才允许写入普通文件或 FIFO。
+// 字符设备以非阻塞方式打开，每次写入都设置期限，主机不接收报文时不会永久阻塞。
+type HIDDevice struct {
+	path         string
+	fileSink     bool
+	writeTimeout time.Duration // 单个报文写入期限，超时返回 ErrHostNotReady
+	onOutput     func(report []byte)
+
+	writes chan hidWrite
+	closed chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	// 以下字段只由写协程修改
+	file    *os.File
+	opened  bool // 是否曾经成功打开过
+	backoff time.Duration
+
+	healthMu sync.RWMutex
+	health   DeviceHealth
+}
+
+// HIDDeviceConfig HID 设备配置
+type HIDDeviceConfig struct {
+	Path         string
+	FileSink     bool                // 允许写入普通文件或 FIFO
+	WriteTimeout time.Duration       // 单个报文写入期限，非正数时使用 DefaultHIDWriteTimeout
+	OnOutput     func(report []byte) // 收到主机输出报文（如键盘 LED）时的回调，为 nil 时不读取
+}
+
+// NewHIDDevice 创建 HID 设备并启动写协程，设备在首次写入时打开
+func NewHIDDevice(config HIDDeviceConfig) *HIDDevice {
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultHIDWriteTimeout
+	}
+	d := &HIDDevice{
+		path:         config.Path,
+		fileSink:     config.FileSink,
+		writeTimeout: config.WriteTimeout,
+		onOutput:     config.OnOutput,
+		writes:       make(chan hidWrite),
+		closed:       make(chan struct{}),
+		health:       DeviceHealth{Path: config.Path, FileSink: config.FileSink},
+	}
+	d.wg.Add(1)
+	go d.loop()
+	return d
+}
+
+// Write 写入一份报文，等待写协程完成
+// 排队和写入共用同一个期限，超时返回 ErrHostNotReady
+func (d *HIDDevice) Write(report []byte) error {
+	w := hidWrite{report: report, done: make(chan error, 1)}
+	timer := time.NewTimer(d.writeTimeout)
+	defer timer.Stop()
+
+	select {
+	case d.writes <- w:
+	case <-d.closed:
+		return ErrDeviceClosed
+	case <-timer.C:
+		return d.hostNotReady()
+	}
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-timer.C:
+		return d.hostNotReady()
+	}
+}
+
+// hostNotReady 构造写入超时错误
+func (d *HIDDevice) hostNotReady() error {
+	return fmt.Errorf("%w: %v 内未能写入 %s", ErrHostNotReady, d.writeTimeout, d.path)
+}
+
+// Health 返回设备健康状态
+func (d *HIDDevice) Health() DeviceHealth {
+	d.healthMu.RLock()
+	defer d.healthMu.RUnlock()
+	return d.health
+}
+
+// Close 停止写协程并关闭文件句柄
+func (d *HIDDevice) Close() error {
+	d.once.Do(func() {
+		close(d.closed)
+	})
+	d.wg.Wait()
+	return nil
+}
+
+// loop 写协程：串行处理写入，设备断开时在后台按退避时间重新打开
+func (d *HIDDevice) loop() {
+	defer d.wg.Done()
+	defer d.closeFile()
+
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-d.closed:
+			return
+		case w := <-d.writes:
+			w.done <- d.write(w.report)
+		case <-retry:
+			retry = nil
+			d.open()
+		}
+		if next := d.Health().NextRetry; d.file == nil && retry == nil && !next.IsZero() {
+			retry = time.After(time.Until(next))
+		}
+	}
+}
+
+// write 写入报文，设备未打开时先尝试打开
+func (d *HIDDevice) write(report []byte) error {
+	if d.file == nil {
+		if next := d.Health().NextRetry; !next.IsZero() && time.Now().Before(next) {
+			err := fmt.Errorf("hid 设备不可用，%v 后重试: %s", time.Until(next).Round(time.Millisecond), d.Health().LastError)
+			d.recordWrite(err)
+			return err
+		}
+		if err := d.open(); err != nil {
+			d.recordWrite(err)
+			return err
+		}
+	}
+
+	// 普通文件不支持期限（ErrNoDeadline），写入本身不会阻塞
+	d.file.SetWriteDeadline(time.Now().Add(d.writeTimeout))
+	_, err := d.file.Write(report)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = d.hostNotReady()
+	} else if err != nil {
+		err = fmt.Errorf("写入 hid 报文失败: %w", err)
+		if isHIDDisconnectError(err) {
+			log.Printf("[HID] 设备 %s 已断开: %v", d.path, err)
+			d.closeFile()
+			d.scheduleRetry(err)
+		}
+	}
+	d.recordWrite(err)
+	return err
+}
+
+// open 打开设备文件，失败时安排退避重试
+func (d *HIDDevice) open() error {
+	file, err := d.openFile()
+	if err != nil {
+		d.scheduleRetry(err)
+		return err
+	}
+
+	d.file = file
+	d.backoff = 0
+	if d.onOutput != nil && d.isCharDevice(file) {
+		d.wg.Add(1)
+		go d.readLoop(file)
+	}
+	d.healthMu.Lock()
+	if d.opened {
+		d.health.Reopens++
+		log.Printf("[HID] 设备 %s 已重新打开", d.path)
+	}
+	d.opened = true
+	d.health.Open = true
+	d.health.NextRetry = time.Time{}
+	d.healthMu.Unlock()
+	return nil
+}
+
+// openFile 按设备类型打开文件：字符设备直接打开；
+// 文件模式下 FIFO 以读写方式打开（避免等待读端），普通文件以追加方式打开（不存在时创建）
+func (d *HIDDevice) openFile() (*os.File, error) {
+	info, err := os.Stat(d.path)
+	switch {
+	case err == nil && info.Mode()&os.ModeCharDevice != 0:
+		// 非阻塞打开后由 Go 运行时轮询，写入期限才能生效
+		file, err := os.OpenFile(d.path, os.O_RDWR|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return nil, fmt.Errorf("打开 hid 设备失败: %v", err)
+		}
+		return file, nil
+
+	case !d.fileSink && err == nil:
+		return nil, fmt.Errorf("%s 不是字符设备，如需写入普通文件请启用文件输出模式", d.path)
+
+	case !d.fileSink:
+		return nil, fmt.Errorf("hid 设备不存在: %v", err)
+
+	case err == nil && info.Mode()&os.ModeNamedPipe != 0:
+		file, err := os.OpenFile(d.path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, fmt.Errorf("打开 fifo 失败: %v", err)
+		}
+		return file, nil
+
+	default:
+		file, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("打开输出文件失败: %v", err)
+		}
+		return file, nil
+	}
+}
+
+// isCharDevice 判断已打开的文件是否为字符设备（只有真实 gadget 设备会收到输出报文）
+func (d *HIDDevice) isCharDevice(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readLoop 读取主机发来的输出报文，文件句柄关闭后退出
+func (d *HIDDevice) readLoop(file *os.File) {
+	defer d.wg.Done()
+	buf := make([]byte, 64)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("[HID] 读取输出报文结束: %v", err)
+			}
+			return
+		}
+		if n > 0 {
+			report := make([]byte, n)
+			copy(report, buf[:n])
+			d.onOutput(report)
+		}
+	}
+}
+
+// closeFile 关闭当前文件句柄
+func (d *HIDDevice) closeFile() {
+	if d.file == nil {
+		return
+	}
+	d.file.Close()
+	d.file = nil
+	d.healthMu.Lock()
+	d.health.Open = false
+	d.healthMu.Unlock()
+}
+
+// scheduleRetry 记录错误并按指数退避安排下一次打开
+func (d *HIDDevice) scheduleRetry(err error) {
+	if d.backoff == 0 {
+		d.backoff = hidReopenMinBackoff
+	} else if d.backoff *= 2; d.backoff > hidReopenMaxBackoff {
+		d.backoff = hidReopenMaxBackoff
+	}
+	d.healthMu.Lock()
+	d.health.LastError = err.Error()
+	d.health.LastErrorTime = time.Now()
+	d.health.NextRetry = time.Now().Add(d.backoff)
+	d.healthMu.Unlock()
+}
+
+// recordWrite 更新写入统计
+func (d *HIDDevice) recordWrite(err error) {
+	d.healthMu.Lock()
+	defer d.healthMu.Unlock()
+	if err != nil {
+		d.health.Failed++
+		d.health.LastError = err.Error()
+		d.health.LastErrorTime = time.Now()
+		return
+	}
+	d.health.Written++
+}
+
+// isHIDDisconnectError 判断是否为主机断开导致的写入错误
+func isHIDDisconnectError(err error) bool {
+	return errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.ESHUTDOWN) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.EIO)
+}
@@ -0,0 +1,51 @@
+package act
+
+import (
+	"log"
+	"os"
+	"pi-keyboard/hid"
+	"strings"
+	"time"
+)
+
+// protocolPollInterval 协议文件轮询间隔
+const protocolPollInterval = 500 * time.Millisecond
+
+// HIDProtocolController 可切换 HID 协议的驱动（可选能力）
+//
+// NKRO 模式下切换到 boot 协议后（BIOS/UEFI），驱动改为发送 8 字节 boot 报文。
+// 标准 f_hid 不向用户态上报 SET_PROTOCOL，协议只能由协议文件或 /protocol 接口告知驱动，不会自动检测。
+type HIDProtocolController interface {
+	// HIDProtocol 返回当前协议（boot/report）以及报告协议下是否使用 NKRO 报文
+	HIDProtocol() (protocol string, nkro bool)
+	// SetHIDProtocol 切换协议，并按新格式重发当前按键状态
+	SetHIDProtocol(protocol string) error
+}
+
+// watchProtocolFile 轮询协议文件，内容变化时切换协议
+// 文件内容为主机通过 SET_PROTOCOL 选择的协议（0/boot 或 1/report），须由打过补丁的内核或辅助程序提供
+func watchProtocolFile(path string, stop <-chan struct{}, apply func(protocol byte)) {
+	ticker := time.NewTicker(protocolPollInterval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		if data, err := os.ReadFile(path); err == nil {
+			value := strings.TrimSpace(string(data))
+			if value != last {
+				last = value
+				if protocol, err := hid.ParseProtocol(value); err != nil {
+					log.Printf("[OTG] 协议文件 %s 内容无效: %v", path, err)
+				} else {
+					apply(protocol)
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUDCRoot 默认的 UDC sysfs 目录
+const DefaultUDCRoot = "/sys/class/udc"
+
+// hostPollInterval UDC 状态轮询间隔
+const hostPollInterval = 250 * time.Millisecond
+
+// UDC 状态（内核 usb_state_string 的取值）
+const (
+	HostNotAttached = "not attached" // 线缆未连接
+	HostAttached    = "attached"
+	HostPowered     = "powered"
+	HostDefault     = "default"    // 已复位，尚未分配地址
+	HostAddressed   = "addressed"  // 枚举中
+	HostConfigured  = "configured" // 主机已完成枚举，可以收发报文
+	HostSuspended   = "suspended"  // 主机休眠或 USB 口挂起
+	HostUnknown     = "unknown"    // 未找到 UDC
+)
+
+// ActionWaitHost 批量请求中等待主机就绪的特殊动作，KeyRequest.Duration 为等待期限
+const ActionWaitHost = "wait_host"
+
+// ErrHostWaitTimeout 等待主机状态超时
+var ErrHostWaitTimeout = errors.New("等待主机状态超时")
+
+// HostState USB 主机连接状态
+type HostState struct {
+	UDC     string    `json:"udc"`
+	State   string    `json:"state"`
+	Ready   bool      `json:"ready"` // State 为 configured
+	Since   time.Time `json:"since"` // 进入当前状态的时间
+	Changes int       `json:"changes"`
+}
+
+// HostEvent 主机状态变化事件
+type HostEvent struct {
+	UDC      string    `json:"udc"`
+	State    string    `json:"state"`
+	Previous string    `json:"previous"`
+	Time     time.Time `json:"time"`
+}
+
+// HostStateReporter 可检测 USB 主机连接状态的驱动（可选能力）
+type HostStateReporter interface {
+	HostMonitor() *HostMonitor
+}
+
+// HostMonitorFor 返回驱动（或其包装链）的主机状态监视器，驱动无法检测主机状态时返回 false
+func HostMonitorFor(driver KeyboardDriver) (*HostMonitor, bool) {
+	reporter, ok := driverCapability[HostStateReporter](driver)
+	if !ok {
+		return nil, false
+	}
+	return reporter.HostMonitor(), true
+}
+
+// HostMonitor 轮询 <root>/<udc>/state，跟踪主机枚举、休眠、拔出
+//
+// root 可配置，测试时可以指向包含假 state 文件的临时目录。
+type HostMonitor struct {
+	root string
+	udc  string // 为空时使用 root 下第一个 UDC
+
+	mu          sync.Mutex
+	state       HostState
+	changed     chan struct{} // 状态变化时关闭并替换，用于唤醒等待者
+	subscribers map[chan HostEvent]struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHostMonitor 创建并启动主机状态监视器
+func NewHostMonitor(root, udc string) *HostMonitor {
+	if root == "" {
+		root = DefaultUDCRoot
+	}
+	m := &HostMonitor{
+		root:        root,
+		udc:         udc,
+		state:       HostState{UDC: udc, State: HostUnknown, Since: time.Now()},
+		changed:     make(chan struct{}),
+		subscribers: make(map[chan HostEvent]struct{}),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	m.poll()
+	go m.loop()
+	return m
+}
+
+// State 返回当前主机状态
+func (m *HostMonitor) State() HostState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Subscribe 订阅状态变化事件，返回取消订阅函数
+// 订阅者处理过慢时丢弃事件，不阻塞监视器
+func (m *HostMonitor) Subscribe() (<-chan HostEvent, func()) {
+	ch := make(chan HostEvent, 16)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subscribers, ch)
+			m.mu.Unlock()
+		})
+	}
+}
+
+// WaitFor 等待主机进入指定状态，ctx 结束时返回 ErrHostWaitTimeout
+func (m *HostMonitor) WaitFor(ctx context.Context, state string) error {
+	for {
+		m.mu.Lock()
+		current, changed := m.state.State, m.changed
+		m.mu.Unlock()
+		if current == state {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: 期望 %s，当前 %s", ErrHostWaitTimeout, state, current)
+		case <-m.stop:
+			return fmt.Errorf("主机状态监视器已关闭")
+		}
+	}
+}
+
+// WaitConfigured 等待主机完成枚举，最多等待 timeout
+func (m *HostMonitor) WaitConfigured(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return m.WaitFor(ctx, HostConfigured)
+}
+
+// Close 停止监视
+func (m *HostMonitor) Close() {
+	select {
+	case <-m.stop:
+		return
+	default:
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// loop 轮询 state 文件
+func (m *HostMonitor) loop() {
+	defer close(m.done)
+	ticker := time.NewTicker(hostPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.poll()
+		}
+	}
+}
+
+// poll 读取一次 state 文件，状态变化时通知等待者和订阅者
+func (m *HostMonitor) poll() {
+	udc, state := m.read()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if udc == m.state.UDC && state == m.state.State {
+		return
+	}
+
+	event := HostEvent{UDC: udc, State: state, Previous: m.state.State, Time: time.Now()}
+	m.state = HostState{
+		UDC:     udc,
+		State:   state,
+		Ready:   state == HostConfigured,
+		Since:   event.Time,
+		Changes: m.state.Changes + 1,
+	}
+	close(m.changed)
+	m.changed = make(chan struct{})
+	for ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	log.Printf("[HOST] %s 状态: %s -> %s", udc, event.Previous, state)
+}
+
+// read 读取 UDC 名称和状态
+func (m *HostMonitor) read() (string, string) {
+	udc := m.udc
+	if udc == "" {
+		entries, err := os.ReadDir(m.root)
+		if err != nil || len(entries) == 0 {
+			return "", HostUnknown
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		udc = names[0]
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.root, udc, "state"))
+	if err != nil {
+		return udc, HostUnknown
+	}
+	return udc, strings.TrimSpace(string(data))
+}
@@ -0,0 +1,184 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setUDCState 写入 <root>/<udc>/state，模拟内核更新 UDC 状态
+// 先写临时文件再重命名，监视器的轮询协程不会读到写了一半的文件
+func setUDCState(t *testing.T, root, udc, state string) {
+	t.Helper()
+	dir := filepath.Join(root, udc)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(dir, ".state.tmp")
+	if err := os.WriteFile(tmp, []byte(state+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "state")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestHostMonitor(t *testing.T, root, udc string) *HostMonitor {
+	t.Helper()
+	m := NewHostMonitor(root, udc)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func nextHostEvent(t *testing.T, events <-chan HostEvent) HostEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("未收到主机状态事件")
+		return HostEvent{}
+	}
+}
+
+func TestHostMonitorUnknown(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "udc")
+	m := newTestHostMonitor(t, root, "")
+	if state := m.State(); state.State != HostUnknown || state.Ready || state.UDC != "" {
+		t.Errorf("UDC 目录不存在时状态为 %+v，期望 unknown", state)
+	}
+
+	// 指定的 UDC 没有 state 文件
+	if err := os.MkdirAll(filepath.Join(root, "fe980000.usb"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m = newTestHostMonitor(t, root, "fe980000.usb")
+	if state := m.State(); state.State != HostUnknown || state.UDC != "fe980000.usb" {
+		t.Errorf("state 文件不存在时状态为 %+v，期望 unknown", state)
+	}
+}
+
+func TestHostMonitorFirstUDC(t *testing.T) {
+	root := t.TempDir()
+	setUDCState(t, root, "musb-hdrc.1", HostSuspended)
+	setUDCState(t, root, "fe980000.usb", HostConfigured)
+
+	m := newTestHostMonitor(t, root, "")
+	state := m.State()
+	if state.UDC != "fe980000.usb" || state.State != HostConfigured || !state.Ready {
+		t.Errorf("状态为 %+v，期望按名称排序的第一个 UDC fe980000.usb 且 configured", state)
+	}
+
+	m = newTestHostMonitor(t, root, "musb-hdrc.1")
+	if state := m.State(); state.UDC != "musb-hdrc.1" || state.State != HostSuspended {
+		t.Errorf("指定 UDC 时状态为 %+v，期望 musb-hdrc.1 suspended", state)
+	}
+}
+
+func TestHostMonitorPollEvents(t *testing.T) {
+	root := t.TempDir()
+	setUDCState(t, root, "fe980000.usb", HostNotAttached)
+	m := newTestHostMonitor(t, root, "")
+	events, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	transitions := []string{HostDefault, HostAddressed, HostConfigured, HostSuspended, HostNotAttached}
+	previous := HostNotAttached
+	for _, state := range transitions {
+		setUDCState(t, root, "fe980000.usb", state)
+		m.poll()
+		event := nextHostEvent(t, events)
+		if event.UDC != "fe980000.usb" || event.State != state || event.Previous != previous {
+			t.Errorf("事件为 %+v，期望 %s -> %s", event, previous, state)
+		}
+		previous = state
+	}
+	if changes := m.State().Changes; changes != len(transitions)+1 {
+		t.Errorf("Changes = %d，期望 %d", changes, len(transitions)+1)
+	}
+
+	// 状态未变化时不产生事件
+	m.poll()
+	select {
+	case event := <-events:
+		t.Errorf("状态未变化却收到事件 %+v", event)
+	default:
+	}
+
+	// UDC 消失后回到 unknown
+	if err := os.RemoveAll(filepath.Join(root, "fe980000.usb")); err != nil {
+		t.Fatal(err)
+	}
+	m.poll()
+	if event := nextHostEvent(t, events); event.State != HostUnknown || event.Previous != HostNotAttached {
+		t.Errorf("UDC 消失后事件为 %+v，期望 not attached -> unknown", event)
+	}
+}
+
+func TestHostMonitorWaitFor(t *testing.T) {
+	root := t.TempDir()
+	setUDCState(t, root, "fe980000.usb", HostNotAttached)
+	m := newTestHostMonitor(t, root, "")
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- m.WaitFor(ctx, HostConfigured)
+	}()
+
+	// 中间状态不唤醒等待者
+	setUDCState(t, root, "fe980000.usb", HostAddressed)
+	m.poll()
+	select {
+	case err := <-done:
+		t.Fatalf("主机未就绪时 WaitFor 返回了 %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	setUDCState(t, root, "fe980000.usb", HostConfigured)
+	m.poll()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitFor: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("主机就绪后 WaitFor 未返回")
+	}
+
+	// 已处于目标状态时立即返回
+	if err := m.WaitFor(context.Background(), HostConfigured); err != nil {
+		t.Errorf("已就绪时 WaitFor: %v", err)
+	}
+}
+
+func TestHostMonitorWaitForTimeout(t *testing.T) {
+	root := t.TempDir()
+	setUDCState(t, root, "fe980000.usb", HostSuspended)
+	m := newTestHostMonitor(t, root, "")
+
+	err := m.WaitConfigured(20 * time.Millisecond)
+	if !errors.Is(err, ErrHostWaitTimeout) {
+		t.Fatalf("WaitConfigured 返回 %v，期望 ErrHostWaitTimeout", err)
+	}
+}
+
+func TestHostMonitorWaitForClosed(t *testing.T) {
+	m := NewHostMonitor(t.TempDir(), "")
+	done := make(chan error, 1)
+	go func() { done <- m.WaitFor(context.Background(), HostConfigured) }()
+
+	m.Close()
+	select {
+	case err := <-done:
+		if err == nil || errors.Is(err, ErrHostWaitTimeout) {
+			t.Errorf("监视器关闭后 WaitFor 返回 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("监视器关闭后 WaitFor 未返回")
+	}
+}
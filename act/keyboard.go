@@ -4,69 +4,40 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
-// HID 键盘 keycode 映射表（部分，后续可扩展）
-var keyMap = map[string]byte{
-	// 字母
-	"a": 0x04, "b": 0x05, "c": 0x06, "d": 0x07, "e": 0x08,
-	"f": 0x09, "g": 0x0a, "h": 0x0b, "i": 0x0c, "j": 0x0d,
-	"k": 0x0e, "l": 0x0f, "m": 0x10, "n": 0x11, "o": 0x12,
-	"p": 0x13, "q": 0x14, "r": 0x15, "s": 0x16, "t": 0x17,
-	"u": 0x18, "v": 0x19, "w": 0x1a, "x": 0x1b, "y": 0x1c, "z": 0x1d,
-	// 数字
-	"1": 0x1e, "2": 0x1f, "3": 0x20, "4": 0x21, "5": 0x22,
-	"6": 0x23, "7": 0x24, "8": 0x25, "9": 0x26, "0": 0x27,
-	// 控制键
-	"enter": 0x28, "esc": 0x29, "backspace": 0x2a, "tab": 0x2b, "space": 0x2c,
-	"-": 0x2d, "=": 0x2e, "[": 0x2f, "]": 0x30, "\\": 0x31,
-	"nonus#": 0x32, // 非美式#和~，部分键盘
-	";":      0x33, "'": 0x34, "`": 0x35, ",": 0x36, ".": 0x37, "/": 0x38,
-	"capslock": 0x39,
-	// 功能键
-	"f1": 0x3a, "f2": 0x3b, "f3": 0x3c, "f4": 0x3d, "f5": 0x3e, "f6": 0x3f,
-	"f7": 0x40, "f8": 0x41, "f9": 0x42, "f10": 0x43, "f11": 0x44, "f12": 0x45,
-	"printscreen": 0x46, "scrolllock": 0x47, "pause": 0x48, "insert": 0x49,
-	"home": 0x4a, "pageup": 0x4b, "delete": 0x4c, "end": 0x4d, "pagedown": 0x4e,
-	"right": 0x4f, "left": 0x50, "down": 0x51, "up": 0x52,
-	// 小键盘
-	"numlock": 0x53, "kp/": 0x54, "kp*": 0x55, "kp-": 0x56, "kp+": 0x57,
-	"kpenter": 0x58, "kp1": 0x59, "kp2": 0x5a, "kp3": 0x5b, "kp4": 0x5c,
-	"kp5": 0x5d, "kp6": 0x5e, "kp7": 0x5f, "kp8": 0x60, "kp9": 0x61, "kp0": 0x62,
-	"kp.": 0x63, "nonus\\": 0x64,
-	// 修饰键
-	"application": 0x65, "power": 0x66, "kpequal": 0x67,
-	"f13": 0x68, "f14": 0x69, "f15": 0x6a, "f16": 0x6b, "f17": 0x6c, "f18": 0x6d,
-	"f19": 0x6e, "f20": 0x6f, "f21": 0x70, "f22": 0x71, "f23": 0x72, "f24": 0x73,
-	// 控制/系统
-	"control": 0xe0, "shift": 0xe1, "alt": 0xe2, "gui": 0xe3, // 左侧
-	"rcontrol": 0xe4, "rshift": 0xe5, "ralt": 0xe6, "rgui": 0xe7, // 右侧
-	// 媒体/系统控制（部分键盘支持）
-	"mute": 0x7f, "volumeup": 0x80, "volumedown": 0x81,
-	// 国际键（部分键盘支持）
-	"intl1": 0x87, "intl2": 0x88, "intl3": 0x89, "intl4": 0x8a, "intl5": 0x8b,
-	"intl6": 0x8c, "intl7": 0x8d, "intl8": 0x8e, "intl9": 0x8f,
-	// 其它常见键
-	"pausebreak": 0x48, "win": 0xe3, "menu": 0x65,
-}
-
 // KeyRequest 按键请求（简化版，去掉Response通道）
 type KeyRequest struct {
 	Key         string
+	Modifiers   []string // 按键期间需要按住的修饰键（组合键中主按键之前的按键）
+	Action      string   // 为空表示按下并释放，down/up 表示只按下或只释放
 	Duration    time.Duration
+	Gap         time.Duration // 顺序执行时本按键之后的等待时间
 	ClientIP    string
 	RequestTime time.Time
 }
 
+// label 返回用于日志的按键描述，组合键显示为 "control+shift+t"
+func (r KeyRequest) label() string {
+	if len(r.Modifiers) == 0 {
+		return r.Key
+	}
+	return strings.Join(append(append([]string{}, r.Modifiers...), r.Key), ChordSeparator)
+}
+
 // KeyResponse 按键响应
 type KeyResponse struct {
 	Success bool
@@ -76,28 +47,54 @@ type KeyResponse struct {
 
 // TypeRequest 文本输入请求
 type TypeRequest struct {
-	Text string `json:"text"`
+	Text     string `json:"text"`
+	Duration int    `json:"duration,omitempty"`  // 每个按键按住时间（毫秒），默认 50
+	Interval int    `json:"interval,omitempty"`  // 字符间隔（毫秒），默认 10
+	Layout   string `json:"layout,omitempty"`    // 主机键盘布局，为空时使用默认布局
+	Unicode  string `json:"unicode,omitempty"`   // 布局无法输入的字符的输入策略，为空时使用默认策略，none 表示不启用
+	CapsLock string `json:"caps_lock,omitempty"` // 主机 Caps Lock 开启时的补偿方式：shift、toggle、ignore，为空时使用默认方式
+	WaitHost int    `json:"wait_host,omitempty"` // 输入前等待主机完成枚举（configured）的最长时间（毫秒），0 表示不等待
 }
 
 // Action 批量操作
 type Action struct {
 	Key      string `json:"key"`
 	Duration int    `json:"duration,omitempty"`
+	Gap      int    `json:"gap,omitempty"` // 本操作之后的等待时间（毫秒），默认使用请求的 gap
+}
+
+// ActionsRequest 带选项的批量操作请求
+// /actions 也接受直接的 Action 数组
+type ActionsRequest struct {
+	Layout   string   `json:"layout,omitempty"`    // 指定后单字符按键按该布局的字符解释
+	Gap      int      `json:"gap,omitempty"`       // 操作之间的默认等待时间（毫秒），默认 10
+	WaitHost int      `json:"wait_host,omitempty"` // 执行前等待主机完成枚举（configured）的最长时间（毫秒），0 表示不等待
+	Actions  []Action `json:"actions"`
 }
 
 type Keyboard struct {
-	driver KeyboardDriver
-	stats  *KeyboardStats
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
-	// 移除 requestChan，改为直接并发处理
+	driver       KeyboardDriver
+	layout       *Layout         // 默认主机键盘布局
+	unicode      UnicodeStrategy // 默认 Unicode 输入策略，nil 表示不启用
+	capsLockMode string          // 默认 Caps Lock 补偿方式
+	stats        *KeyboardStats
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+
+	// 单个按键请求直接并发处理；批量操作和文本输入由执行器按顺序执行
+	executor *SequentialExecutor
 
 	// 记录相关
 	recording  bool
 	recordFile *os.File
 	recordMu   sync.Mutex
 	recordName string
+
+	// 回放相关：取消 replayCtx 即停止所有排队和执行中的回放
+	replayMu     sync.Mutex
+	replayCtx    context.Context
+	replayCancel context.CancelFunc
 }
 
 // KeyboardStats 统计信息
@@ -133,8 +130,11 @@ type LatencyRecord struct {
 
 func NewKeyboard(driver KeyboardDriver) *Keyboard {
 	ctx, cancel := context.WithCancel(context.Background())
+	layout, _ := GetLayout(DefaultLayoutName)
 	k := &Keyboard{
-		driver: driver,
+		driver:       driver,
+		layout:       layout,
+		capsLockMode: CapsLockShift,
 		stats: &KeyboardStats{
 			LastKeyDown:     make(map[string]time.Time),
 			LastKeyDuration: make(map[string]time.Duration),
@@ -142,19 +142,56 @@ func NewKeyboard(driver KeyboardDriver) *Keyboard {
 		ctx:    ctx,
 		cancel: cancel,
 	}
+	k.executor = NewSequentialExecutor(ctx, driver.GetDriverType(), k.handleSingleRequest)
 
-	log.Printf("[KEYBOARD] 并发键盘处理器启动 - 直接并发处理，无队列")
+	log.Printf("[KEYBOARD] 键盘处理器启动 - 单键并发处理，批量操作按目标顺序执行")
 	return k
 }
 
-// handleSingleRequest 处理单个按键请求（现在直接并发执行）
-func (k *Keyboard) handleSingleRequest(req KeyRequest) {
+// SetLayout 设置文本输入使用的默认主机键盘布局
+func (k *Keyboard) SetLayout(layout *Layout) {
+	k.layout = layout
+}
+
+// SetUnicodeStrategy 设置布局无法输入的字符的默认输入策略
+func (k *Keyboard) SetUnicodeStrategy(strategy UnicodeStrategy) {
+	k.unicode = strategy
+}
+
+// SetCapsLockMode 设置主机 Caps Lock 开启时文本输入的默认补偿方式
+func (k *Keyboard) SetCapsLockMode(mode string) error {
+	mode, err := ValidateCapsLockMode(mode)
+	if err != nil {
+		return err
+	}
+	k.capsLockMode = mode
+	return nil
+}
+
+// resolveUnicodeStrategy 解析请求指定的 Unicode 输入策略，为空时返回默认策略
+func (k *Keyboard) resolveUnicodeStrategy(name string) (UnicodeStrategy, error) {
+	if name == "" {
+		return k.unicode, nil
+	}
+	return GetUnicodeStrategy(name)
+}
+
+// resolveLayout 解析请求指定的布局，为空时返回默认布局
+func (k *Keyboard) resolveLayout(name string) (*Layout, error) {
+	if name == "" {
+		return k.layout, nil
+	}
+	return GetLayout(name)
+}
+
+// handleSingleRequest 处理单个按键请求
+func (k *Keyboard) handleSingleRequest(req KeyRequest) error {
 	atomic.AddInt64(&k.stats.CurrentlyProcessing, 1)
 	defer atomic.AddInt64(&k.stats.CurrentlyProcessing, -1)
 
 	// 执行按键操作
 	driverStartTime := time.Now()
-	err := k.driver.Press(req.Key, req.Duration)
+	err := k.executeKeyRequest(req)
 	processLatency := time.Since(driverStartTime)
 
 	// 计算总延迟
@@ -166,11 +203,99 @@ func (k *Keyboard) handleSingleRequest(req KeyRequest) {
 
 	if err != nil {
 		log.Printf("[KEYBOARD] 按键失败: %s (%v) - %s | 总延迟:%v 处理:%v",
-			req.Key, err, req.ClientIP, totalLatency, processLatency)
+			req.label(), err, req.ClientIP, totalLatency, processLatency)
 	} else {
 		log.Printf("[KEYBOARD] 按键成功: %s - %s | 总延迟:%v 处理:%v",
-			req.Key, req.ClientIP, totalLatency, processLatency)
+			req.label(), req.ClientIP, totalLatency, processLatency)
+	}
+	return err
+}
+
+// executeKeyRequest 按请求的动作调用驱动
+func (k *Keyboard) executeKeyRequest(req KeyRequest) error {
+	switch req.Action {
+	case ActionWaitHost:
+		return k.waitForHost(req.Duration)
+	case StrokeDown:
+		return k.driver.KeyDown(req.Key)
+	case StrokeUp:
+		return k.driver.KeyUp(req.Key)
+	default:
+		return PressChord(k.driver, append(append([]string{}, req.Modifiers...), req.Key), req.Duration)
+	}
+}
+
+// waitForHost 等待主机完成枚举，驱动无法检测主机状态时直接返回
+func (k *Keyboard) waitForHost(timeout time.Duration) error {
+	monitor, ok := HostMonitorFor(k.driver)
+	if !ok {
+		return nil
+	}
+	if monitor.State().Ready {
+		return nil
 	}
+	log.Printf("[HOST] 等待主机完成枚举，最长 %v", timeout)
+	return monitor.WaitConfigured(timeout)
+}
+
+// waitHostRequest 返回批量请求开头的等待主机请求，ms 为 0 时返回 nil
+func waitHostRequest(ms int, clientIP string) []KeyRequest {
+	if ms <= 0 {
+		return nil
+	}
+	return []KeyRequest{{
+		Action:      ActionWaitHost,
+		Duration:    time.Duration(ms) * time.Millisecond,
+		ClientIP:    clientIP,
+		RequestTime: time.Now(),
+	}}
+}
+
+// strokeRequest 将按键组合转换为按键请求
+func strokeRequest(stroke KeyStroke, duration, gap time.Duration, clientIP string) KeyRequest {
+	return KeyRequest{
+		Key:         stroke.Key,
+		Modifiers:   stroke.Modifiers,
+		Action:      stroke.Action,
+		Duration:    duration,
+		Gap:         gap,
+		ClientIP:    clientIP,
+		RequestTime: time.Now(),
+	}
+}
+
+// parseKey 解析单键或组合键表达式（如 "ctrl+shift+t"），校验每个按键后返回标准名的主按键和需要按住的按键
+func (k *Keyboard) parseKey(expr string) (string, []string, error) {
+	key := CanonicalKey(expr)
+	if !IsChord(key) {
+		if !k.driver.IsKeySupported(key) {
+			return "", nil, fmt.Errorf("不支持的按键: %s", expr)
+		}
+		return key, nil, nil
+	}
+	keys, err := ParseChord(key, k.driver)
+	if err != nil {
+		return "", nil, err
+	}
+	return keys[len(keys)-1], keys[:len(keys)-1], nil
+}
+
+// submitBatch 将批量按键交给执行器顺序执行，队列已满时返回 503
+func (k *Keyboard) submitBatch(w http.ResponseWriter, name string, requests []KeyRequest, startTime time.Time) bool {
+	if _, err := k.executor.Submit(name, requests); err != nil {
+		k.updateStats(false, time.Since(startTime), true)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// msOrDefault 将毫秒参数转换为时长，非正数时使用默认值
+func msOrDefault(ms int, def time.Duration) time.Duration {
+	if ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return def
 }
 
 // GetStats 获取统计信息
@@ -256,7 +381,7 @@ func (k *Keyboard) updateStatsWithDetails(success bool, totalLatency, processLat
 // PressHandler 按键处理接口（现在直接并发处理）
 func (k *Keyboard) PressHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
-	key := strings.ToLower(r.URL.Query().Get("key"))
+	key := CanonicalKey(r.URL.Query().Get("key"))
 	durationStr := r.URL.Query().Get("duration")
 	clientIP := r.RemoteAddr
 
@@ -268,10 +393,11 @@ func (k *Keyboard) PressHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !k.driver.IsKeySupported(key) {
+	mainKey, modifiers, err := k.parseKey(key)
+	if err != nil {
 		latency := time.Since(startTime)
 		k.updateStats(false, latency, false)
-		http.Error(w, "不支持的按键: "+key, 400)
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
@@ -286,7 +412,8 @@ func (k *Keyboard) PressHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 创建请求
 	req := KeyRequest{
-		Key:         key,
+		Key:         mainKey,
+		Modifiers:   modifiers,
 		Duration:    duration,
 		ClientIP:    clientIP,
 		RequestTime: time.Now(),
@@ -306,7 +433,7 @@ func (k *Keyboard) PressHandler(w http.ResponseWriter, r *http.Request) {
 // PressHandlerSync 同步按键处理接口（直接处理，不使用队列）
 func (k *Keyboard) PressHandlerSync(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
-	key := strings.ToLower(r.URL.Query().Get("key"))
+	key := CanonicalKey(r.URL.Query().Get("key"))
 	durationStr := r.URL.Query().Get("duration")
 	clientIP := r.RemoteAddr
 
@@ -318,10 +445,11 @@ func (k *Keyboard) PressHandlerSync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !k.driver.IsKeySupported(key) {
+	mainKey, modifiers, err := k.parseKey(key)
+	if err != nil {
 		latency := time.Since(startTime)
 		k.updateStats(false, latency, false)
-		http.Error(w, "不支持的按键: "+key, 400)
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
@@ -336,74 +464,150 @@ func (k *Keyboard) PressHandlerSync(w http.ResponseWriter, r *http.Request) {
 
 	// 创建请求
 	req := KeyRequest{
-		Key:         key,
+		Key:         mainKey,
+		Modifiers:   modifiers,
 		Duration:    duration,
 		ClientIP:    clientIP,
 		RequestTime: time.Now(),
 	}
 
 	// 直接同步处理
-	k.handleSingleRequest(req)
+	if err := k.handleSingleRequest(req); err != nil {
+		http.Error(w, "按键处理失败: "+err.Error(), driverErrorStatus(err))
+		return
+	}
+	io.WriteString(w, "ok")
+}
 
-	// 根据最后的统计信息判断是否成功
-	if k.stats.FailedRequests > 0 {
-		http.Error(w, "按键处理失败", 500)
-	} else {
-		io.WriteString(w, "ok")
+// driverErrorStatus 将驱动错误映射为 HTTP 状态码：主机未就绪（写入超时）返回 504，其它返回 500
+func driverErrorStatus(err error) int {
+	if errors.Is(err, ErrHostNotReady) {
+		return http.StatusGatewayTimeout
 	}
+	return http.StatusInternalServerError
 }
 
-// ActionsHandler 批量操作处理（并发处理版）
+// ActionsHandler 批量操作处理（按顺序执行）
 func (k *Keyboard) ActionsHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	clientIP := r.RemoteAddr
 
-	var actions []Action
-	if err := json.NewDecoder(r.Body).Decode(&actions); err != nil {
+	req, err := decodeActionsRequest(r.Body)
+	if err != nil {
 		latency := time.Since(startTime)
 		k.updateStats(false, latency, false)
 		http.Error(w, "JSON 解析失败", 400)
 		return
 	}
 
-	if len(actions) == 0 {
+	if len(req.Actions) == 0 {
 		http.Error(w, "操作列表为空", 400)
 		return
 	}
 
-	// 并发处理所有操作
-	for _, act := range actions {
-		key := strings.ToLower(act.Key)
-		if !k.driver.IsKeySupported(key) {
-			http.Error(w, "不支持的按键: "+act.Key, 400)
+	var layout *Layout
+	if req.Layout != "" {
+		if layout, err = GetLayout(req.Layout); err != nil {
+			http.Error(w, err.Error(), 400)
 			return
 		}
+	}
 
-		duration := 50 * time.Millisecond
-		if act.Duration > 0 {
-			duration = time.Duration(act.Duration) * time.Millisecond
+	// 先校验全部操作，避免部分执行
+	keyReqs := waitHostRequest(req.WaitHost, clientIP)
+	for _, act := range req.Actions {
+		strokes, err := k.actionStrokes(act, layout)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
 		}
 
-		req := KeyRequest{
-			Key:         key,
-			Duration:    duration,
-			ClientIP:    clientIP,
-			RequestTime: time.Now(),
+		duration := msOrDefault(act.Duration, 50*time.Millisecond)
+		gap := msOrDefault(act.Gap, msOrDefault(req.Gap, 10*time.Millisecond))
+
+		for _, stroke := range strokes {
+			keyReqs = append(keyReqs, strokeRequest(stroke, duration, gap, clientIP))
 		}
+	}
 
-		// 每个操作都启动独立的goroutine
-		k.wg.Add(1)
-		go func(r KeyRequest) {
-			defer k.wg.Done()
-			k.handleSingleRequest(r)
-		}(req)
+	// 交给执行器按顺序执行
+	if !k.submitBatch(w, "actions", keyReqs, startTime) {
+		return
 	}
 
 	io.WriteString(w, "processing")
-	log.Printf("[ACTIONS] 批量操作并发处理: %d个操作 - %s", len(actions), clientIP)
+	log.Printf("[ACTIONS] 批量操作已排队: %d个操作 - %s", len(req.Actions), clientIP)
+}
+
+// decodeActionsRequest 解析 /actions 请求体，兼容 Action 数组和 ActionsRequest 对象
+func decodeActionsRequest(body io.Reader) (*ActionsRequest, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	req := &ActionsRequest{}
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		err := json.Unmarshal(raw, &req.Actions)
+		return req, err
+	}
+	err := json.Unmarshal(raw, req)
+	return req, err
+}
+
+// actionStrokes 将单个操作转换为按键组合
+// 指定布局时单字符按键视为字符，按布局转换为实际按键位置；"ctrl+shift+t" 形式的组合键作为一次原子按键
+func (k *Keyboard) actionStrokes(act Action, layout *Layout) ([]KeyStroke, error) {
+	if layout != nil && utf8.RuneCountInString(act.Key) == 1 {
+		char, _ := utf8.DecodeRuneInString(act.Key)
+		if strokes, ok := layout.Strokes(char); ok {
+			for _, stroke := range strokes {
+				if !k.driver.IsKeySupported(stroke.Key) {
+					return nil, fmt.Errorf("不支持的按键: %s", act.Key)
+				}
+			}
+			return strokes, nil
+		}
+	}
+
+	key, modifiers, err := k.parseKey(act.Key)
+	if err != nil {
+		return nil, err
+	}
+	return []KeyStroke{{Key: key, Modifiers: modifiers}}, nil
 }
 
-// TypeHandler 文本输入处理（并发处理版）
+// typeStrokes 将文本逐字符转换为按键序列
+// 按键序列中含驱动不支持的按键（包括修饰键）的字符整体跳过，与布局无法输入的字符一起返回
+func (k *Keyboard) typeStrokes(layout *Layout, text string, fallback UnicodeStrategy) ([]KeyStroke, []rune) {
+	var strokes []KeyStroke
+	var unsupported []rune
+	for _, char := range strings.ReplaceAll(text, "\r\n", "\n") {
+		seq, missing := layout.TextToKeyStrokesWith(string(char), fallback)
+		if len(missing) > 0 || !k.strokesSupported(seq) {
+			unsupported = append(unsupported, char)
+			continue
+		}
+		strokes = append(strokes, seq...)
+	}
+	return strokes, unsupported
+}
+
+// strokesSupported 检查按键序列中的按键和修饰键是否都被驱动支持
+func (k *Keyboard) strokesSupported(strokes []KeyStroke) bool {
+	for _, stroke := range strokes {
+		if !k.driver.IsKeySupported(stroke.Key) {
+			return false
+		}
+		for _, modifier := range stroke.Modifiers {
+			if !k.driver.IsKeySupported(modifier) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TypeHandler 文本输入处理（按顺序执行）
 func (k *Keyboard) TypeHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	clientIP := r.RemoteAddr
@@ -423,34 +627,77 @@ func (k *Keyboard) TypeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 将文本转换为按键序列，并发处理
-	for _, char := range req.Text {
-		key := strings.ToLower(string(char))
-		if k.driver.IsKeySupported(key) {
-			keyReq := KeyRequest{
-				Key:         key,
-				Duration:    50 * time.Millisecond,
-				ClientIP:    clientIP,
-				RequestTime: time.Now(),
-			}
+	layout, err := k.resolveLayout(req.Layout)
+	if err != nil {
+		latency := time.Since(startTime)
+		k.updateStats(false, latency, false)
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
-			// 每个字符都启动独立的goroutine
-			k.wg.Add(1)
-			go func(r KeyRequest) {
-				defer k.wg.Done()
-				k.handleSingleRequest(r)
-			}(keyReq)
+	unicode, err := k.resolveUnicodeStrategy(req.Unicode)
+	if err != nil {
+		latency := time.Since(startTime)
+		k.updateStats(false, latency, false)
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	// 将文本按布局转换为按键序列（含大小写、符号、死键），布局无法输入的字符交给 Unicode 策略
+	strokes, unsupported := k.typeStrokes(layout, req.Text, unicode)
+
+	// 根据主机 LED 状态补偿 Caps Lock
+	capsLockMode := k.capsLockMode
+	if req.CapsLock != "" {
+		if capsLockMode, err = ValidateCapsLockMode(req.CapsLock); err != nil {
+			latency := time.Since(startTime)
+			k.updateStats(false, latency, false)
+			http.Error(w, err.Error(), 400)
+			return
 		}
 	}
+	capsOn, capsKnown := capsLockActive(k.driver)
+	if capsOn {
+		strokes = compensateCapsLock(strokes, capsLockMode)
+	}
 
-	io.WriteString(w, "processing")
-	log.Printf("[TYPE] 文本输入并发处理 - 客户端: %s, 字符数: %d", clientIP, len(req.Text))
+	duration := msOrDefault(req.Duration, 50*time.Millisecond)
+	interval := msOrDefault(req.Interval, 10*time.Millisecond)
+	keyReqs := waitHostRequest(req.WaitHost, clientIP)
+	for _, stroke := range strokes {
+		keyReqs = append(keyReqs, strokeRequest(stroke, duration, interval, clientIP))
+	}
+
+	// 交给执行器按顺序执行，保证字符顺序
+	if len(keyReqs) > 0 && !k.submitBatch(w, "type", keyReqs, startTime) {
+		return
+	}
+
+	// 返回处理结果，无法输入的字符一并告知客户端
+	unsupportedChars := make([]string, 0, len(unsupported))
+	for _, char := range unsupported {
+		unsupportedChars = append(unsupportedChars, string(char))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "processing",
+		"layout":      layout.Name,
+		"keys":        len(strokes),
+		"unsupported": unsupportedChars,
+		"caps_lock": map[string]interface{}{
+			"known": capsKnown,
+			"on":    capsOn,
+			"mode":  capsLockMode,
+		},
+	})
+	log.Printf("[TYPE] 文本输入已排队 - 客户端: %s, 字符数: %d, 无法输入: %d",
+		clientIP, len([]rune(req.Text)), len(unsupported))
 }
 
 // KeyDownHandler 按键按下接口
 func (k *Keyboard) KeyDownHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
-	key := strings.ToLower(r.URL.Query().Get("key"))
+	key := CanonicalKey(r.URL.Query().Get("key"))
 
 	if key == "" {
 		latency := time.Since(startTime)
@@ -470,7 +717,7 @@ func (k *Keyboard) KeyDownHandler(w http.ResponseWriter, r *http.Request) {
 	latency := time.Since(startTime)
 	k.updateStats(err == nil, latency, false)
 	if err != nil {
-		http.Error(w, "按键按下失败: "+err.Error(), 500)
+		http.Error(w, "按键按下失败: "+err.Error(), driverErrorStatus(err))
 		return
 	}
 
@@ -488,7 +735,7 @@ func (k *Keyboard) KeyDownHandler(w http.ResponseWriter, r *http.Request) {
 // KeyUpHandler 按键释放接口
 func (k *Keyboard) KeyUpHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
-	key := strings.ToLower(r.URL.Query().Get("key"))
+	key := CanonicalKey(r.URL.Query().Get("key"))
 
 	if key == "" {
 		latency := time.Since(startTime)
@@ -508,7 +755,7 @@ func (k *Keyboard) KeyUpHandler(w http.ResponseWriter, r *http.Request) {
 	latency := time.Since(startTime)
 	k.updateStats(err == nil, latency, false)
 	if err != nil {
-		http.Error(w, "按键释放失败: "+err.Error(), 500)
+		http.Error(w, "按键释放失败: "+err.Error(), driverErrorStatus(err))
 		return
 	}
 
@@ -570,11 +817,242 @@ func (k *Keyboard) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HealthHandler 驱动与设备健康状态接口
+func (k *Keyboard) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	health := map[string]interface{}{
+		"driver": k.driver.GetDriverType(),
+		"status": "ok",
+	}
+	if reporter, ok := driverCapability[DeviceHealthReporter](k.driver); ok {
+		device := reporter.DeviceHealth()
+		health["device"] = device
+		if !device.Open && device.LastError != "" {
+			health["status"] = "degraded"
+		}
+	}
+	if reporter, ok := driverCapability[HostStateReporter](k.driver); ok {
+		health["host"] = reporter.HostMonitor().State()
+	}
+	if reporter, ok := driverCapability[FaultReporter](k.driver); ok {
+		health["faults"] = reporter.FaultStats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// LEDsHandler 主机键盘 LED 状态接口（Caps/Num/Scroll Lock）
+func (k *Keyboard) LEDsHandler(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := driverCapability[LEDStateReporter](k.driver)
+	if !ok {
+		http.Error(w, "当前驱动不支持读取 LED 状态: "+k.driver.GetDriverType(), http.StatusNotImplemented)
+		return
+	}
+
+	state, known := reporter.LEDState()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"known": known,
+		"leds":  state,
+	})
+}
+
+// KeysHandler 列出当前驱动支持的标准按键和别名
+func (k *Keyboard) KeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys := []KeyInfo{}
+	for _, info := range Keys() {
+		if k.driver.IsKeySupported(info.Name) {
+			keys = append(keys, info)
+		}
+	}
+	aliases := make(map[string]string)
+	for alias, canonical := range KeyAliases() {
+		if k.driver.IsKeySupported(canonical) {
+			aliases[alias] = canonical
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"driver":  k.driver.GetDriverType(),
+		"keys":    keys,
+		"aliases": aliases,
+	})
+}
+
+// ProtocolHandler HID 协议接口
+// GET 返回当前协议；POST ?mode=boot|report 手动切换（主机或内核无法上报 SET_PROTOCOL 时使用）
+func (k *Keyboard) ProtocolHandler(w http.ResponseWriter, r *http.Request) {
+	controller, ok := driverCapability[HIDProtocolController](k.driver)
+	if !ok {
+		http.Error(w, "当前驱动不支持切换 HID 协议: "+k.driver.GetDriverType(), http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			http.Error(w, "缺少 mode 参数 (boot, report)", 400)
+			return
+		}
+		if err := controller.SetHIDProtocol(mode); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+	default:
+		http.Error(w, "只支持 GET 和 POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	protocol, nkro := controller.HIDProtocol()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"protocol": protocol,
+		"nkro":     nkro,
+	})
+}
+
+// hostMonitor 返回驱动的主机状态监视器，驱动不支持时返回 501
+func (k *Keyboard) hostMonitor(w http.ResponseWriter) (*HostMonitor, bool) {
+	monitor, ok := HostMonitorFor(k.driver)
+	if !ok {
+		http.Error(w, "当前驱动不支持检测主机状态: "+k.driver.GetDriverType(), http.StatusNotImplemented)
+		return nil, false
+	}
+	return monitor, true
+}
+
+// HostHandler USB 主机连接状态接口（UDC 状态：configured、suspended、not attached 等）
+func (k *Keyboard) HostHandler(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := k.hostMonitor(w)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(monitor.State())
+}
+
+// HostEventsHandler 以 Server-Sent Events 推送主机状态变化，连接后先推送当前状态
+func (k *Keyboard) HostEventsHandler(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := k.hostMonitor(w)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", 500)
+		return
+	}
+
+	events, unsubscribe := monitor.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	state := monitor.State()
+	writeEvent := func(event HostEvent) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: host\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	writeEvent(HostEvent{UDC: state.UDC, State: state.State, Time: state.Since})
+
+	for {
+		select {
+		case event := <-events:
+			writeEvent(event)
+		case <-r.Context().Done():
+			return
+		case <-k.ctx.Done():
+			return
+		}
+	}
+}
+
+// HostWaitHandler 等待主机进入指定状态（默认 configured），超时返回 504
+// 参数：state 目标状态，timeout 最长等待时间（如 10s，默认 30s）
+func (k *Keyboard) HostWaitHandler(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := k.hostMonitor(w)
+	if !ok {
+		return
+	}
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = HostConfigured
+	}
+	timeout := 30 * time.Second
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "timeout 参数无效: "+value, 400)
+			return
+		}
+		timeout = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	if err := monitor.WaitFor(ctx, state); err != nil {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(monitor.State())
+}
+
+// VirtualHandler 虚拟驱动事件接口
+// GET 返回事件记录（since 参数只返回该序号之后的事件）、主机会看到的文本和按住的按键；DELETE 清空记录
+func (k *Keyboard) VirtualHandler(w http.ResponseWriter, r *http.Request) {
+	recorder, ok := driverCapability[EventRecorder](k.driver)
+	if !ok {
+		http.Error(w, "当前驱动不记录按键事件: "+k.driver.GetDriverType(), http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var since int64
+		if value := r.URL.Query().Get("since"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(w, "since 参数无效: "+value, 400)
+				return
+			}
+			since = parsed
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"driver":  k.driver.GetDriverType(),
+			"events":  recorder.Events(since),
+			"text":    recorder.RenderedText(),
+			"pressed": recorder.PressedKeys(),
+		})
+	case http.MethodDelete:
+		recorder.Reset()
+		io.WriteString(w, "ok")
+	default:
+		http.Error(w, "只支持 GET 和 DELETE", http.StatusMethodNotAllowed)
+	}
+}
+
+// Driver 返回键盘使用的驱动
+func (k *Keyboard) Driver() KeyboardDriver {
+	return k.driver
+}
+
+// RunExclusive 在执行器上独占运行 fn（如宏），与批量操作和文本输入排队执行、互不交错
+// fn 收到的 ctx 在服务关闭时取消；返回的通道在 fn 结束后收到其结果，队列已满时返回 ErrExecutorBusy
+func (k *Keyboard) RunExclusive(name string, fn func(ctx context.Context) error) (<-chan error, error) {
+	return k.executor.SubmitFunc(name, fn)
+}
+
 // Close 关闭键盘服务
 func (k *Keyboard) Close() error {
 	log.Printf("[KEYBOARD] 关闭键盘服务")
 	k.cancel()
-	k.wg.Wait() // 等待所有并发任务完成
+	k.executor.Wait() // 等待执行器退出
+	k.wg.Wait()       // 等待所有并发任务完成
 	return k.driver.Close()
 }
 
@@ -675,3 +1153,166 @@ func (k *Keyboard) RecordKeysHandler(w http.ResponseWriter, r *http.Request) {
 		"filename": filename,
 	})
 }
+
+// ReplayRequest 回放请求
+type ReplayRequest struct {
+	File     string  `json:"file"`       // 工作目录中的记录文件名，如 key_record_1700000000.csv
+	Speed    float64 `json:"speed"`      // 速度倍数，默认 1
+	MaxGapMs int     `json:"max_gap_ms"` // 超过该值的间隔压缩为该值，0 表示不压缩
+	Async    bool    `json:"async"`      // 为 true 时排队后立即返回
+}
+
+// replayContext 返回当前回放批次的 ctx，CancelReplays 后创建新的批次
+func (k *Keyboard) replayContext() context.Context {
+	k.replayMu.Lock()
+	defer k.replayMu.Unlock()
+	if k.replayCtx == nil {
+		k.replayCtx, k.replayCancel = context.WithCancel(k.ctx)
+	}
+	return k.replayCtx
+}
+
+// CancelReplays 停止所有执行中和排队的回放
+func (k *Keyboard) CancelReplays() {
+	k.replayMu.Lock()
+	defer k.replayMu.Unlock()
+	if k.replayCancel != nil {
+		k.replayCancel()
+		k.replayCtx, k.replayCancel = nil, nil
+	}
+}
+
+// ReplayHandler 回放按键记录
+// POST：请求体为 JSON ReplayRequest，或 Content-Type 为 text/csv 的记录内容（参数 speed、max_gap_ms、async 放在查询参数中）；
+// 同步回放时客户端断开即停止。DELETE：停止所有回放
+func (k *Keyboard) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		k.CancelReplays()
+		log.Printf("[REPLAY] 已停止所有回放 - %s", r.RemoteAddr)
+		io.WriteString(w, "ok")
+		return
+	default:
+		http.Error(w, "只支持 POST 和 DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, events, err := decodeReplayRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	opts := ReplayOptions{Speed: req.Speed, MaxGap: time.Duration(req.MaxGapMs) * time.Millisecond}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if len(events) == 0 {
+		http.Error(w, "记录为空", 400)
+		return
+	}
+	if err := ValidateRecord(events, k.driver); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	// 同步回放在客户端断开时取消，异步回放只能通过 DELETE 取消
+	reqCtx := r.Context()
+	if req.Async {
+		reqCtx = context.Background()
+	}
+	replayCtx := k.replayContext()
+	name := req.File
+	if name == "" {
+		name = "upload"
+	}
+	var result ReplayResult
+	done, err := k.RunExclusive("replay "+name, func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(replayCtx, cancel)()
+		defer context.AfterFunc(reqCtx, cancel)()
+
+		log.Printf("[REPLAY] 开始回放 %s: %d个边沿, 计划耗时 %v - %s",
+			name, len(events), ReplaySchedule(events, opts)[len(events)-1], r.RemoteAddr)
+		var replayErr error
+		result, replayErr = Replay(ctx, k.driver, events, opts)
+		log.Printf("[REPLAY] 回放 %s 结束: %d/%d个边沿, 耗时 %v, 最大延迟 %v",
+			name, result.Events, result.Total, result.Duration, result.MaxLag)
+		return replayErr
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.Async {
+		io.WriteString(w, "processing")
+		return
+	}
+	select {
+	case err = <-done:
+	case <-r.Context().Done():
+		return
+	}
+
+	status := http.StatusOK
+	resp := map[string]interface{}{
+		"events":      result.Events,
+		"total":       result.Total,
+		"duration_ms": result.Duration.Milliseconds(),
+		"planned_ms":  result.Planned.Milliseconds(),
+		"max_lag_ms":  float64(result.MaxLag.Microseconds()) / 1000,
+		"released":    result.Released,
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		resp["cancelled"] = true
+	default:
+		status = driverErrorStatus(err)
+		resp["error"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// decodeReplayRequest 解析回放请求，返回请求参数和记录中的边沿
+func decodeReplayRequest(w http.ResponseWriter, r *http.Request) (*ReplayRequest, []RecordedEvent, error) {
+	req := &ReplayRequest{}
+	query := r.URL.Query()
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		if value := query.Get("speed"); value != "" {
+			speed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("speed 参数无效: %s", value)
+			}
+			req.Speed = speed
+		}
+		if value := query.Get("max_gap_ms"); value != "" {
+			ms, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("max_gap_ms 参数无效: %s", value)
+			}
+			req.MaxGapMs = ms
+		}
+		req.Async = query.Get("async") == "true" || query.Get("async") == "1"
+		events, err := ParseRecord(http.MaxBytesReader(w, r.Body, maxReplayUpload))
+		return req, events, err
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, nil, fmt.Errorf("JSON 解析失败")
+	}
+	// 只允许回放工作目录中的记录文件，防止读取任意文件
+	if req.File == "" || filepath.Base(req.File) != req.File || !strings.HasSuffix(req.File, ".csv") {
+		return nil, nil, fmt.Errorf("file 须为工作目录中的 .csv 记录文件名: %q", req.File)
+	}
+	events, err := LoadRecord(req.File)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取记录 %s 失败: %v", req.File, err)
+	}
+	return req, events, nil
+}
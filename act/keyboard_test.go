@@ -0,0 +1,39 @@
+package act
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// limitedDriver 不支持部分按键的驱动
+type limitedDriver struct {
+	*VirtualDriver
+	missing map[string]bool
+}
+
+func (d limitedDriver) IsKeySupported(key string) bool {
+	return !d.missing[CanonicalKey(key)] && d.VirtualDriver.IsKeySupported(key)
+}
+
+func TestTypeStrokesReportsUnsupportedKeys(t *testing.T) {
+	de, err := GetLayout("de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := limitedDriver{VirtualDriver: NewVirtualDriver(de), missing: map[string]bool{"ralt": true, "f1": true}}
+	k := &Keyboard{driver: driver}
+
+	// @ 和 € 在 de 布局下需要 AltGr
+	strokes, unsupported := k.typeStrokes(de, "a@b€\r\n", nil)
+	if want := []rune{'@', '€'}; !reflect.DeepEqual(unsupported, want) {
+		t.Errorf("unsupported = %q，期望 %q", string(unsupported), string(want))
+	}
+	var keys []string
+	for _, stroke := range strokes {
+		keys = append(keys, stroke.Key)
+	}
+	if got := strings.Join(keys, " "); got != "a b enter" {
+		t.Errorf("按键序列为 %q，期望 \"a b enter\"", got)
+	}
+}
@@ -0,0 +1,143 @@
+package act
+
+import (
+	"pi-keyboard/hid"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// keyMap HID 键盘 keycode 映射表，键为标准按键名（别名见 keyAliases）
+var keyMap = map[string]byte{
+	// 字母
+	"a": 0x04, "b": 0x05, "c": 0x06, "d": 0x07, "e": 0x08,
+	"f": 0x09, "g": 0x0a, "h": 0x0b, "i": 0x0c, "j": 0x0d,
+	"k": 0x0e, "l": 0x0f, "m": 0x10, "n": 0x11, "o": 0x12,
+	"p": 0x13, "q": 0x14, "r": 0x15, "s": 0x16, "t": 0x17,
+	"u": 0x18, "v": 0x19, "w": 0x1a, "x": 0x1b, "y": 0x1c, "z": 0x1d,
+	// 数字
+	"1": 0x1e, "2": 0x1f, "3": 0x20, "4": 0x21, "5": 0x22,
+	"6": 0x23, "7": 0x24, "8": 0x25, "9": 0x26, "0": 0x27,
+	// 控制键
+	"enter": 0x28, "esc": 0x29, "backspace": 0x2a, "tab": 0x2b, "space": 0x2c,
+	"-": 0x2d, "=": 0x2e, "[": 0x2f, "]": 0x30, "\\": 0x31,
+	"nonus#": 0x32, // 非美式#和~，部分键盘
+	";":      0x33, "'": 0x34, "`": 0x35, ",": 0x36, ".": 0x37, "/": 0x38,
+	"capslock": 0x39,
+	// 功能键
+	"f1": 0x3a, "f2": 0x3b, "f3": 0x3c, "f4": 0x3d, "f5": 0x3e, "f6": 0x3f,
+	"f7": 0x40, "f8": 0x41, "f9": 0x42, "f10": 0x43, "f11": 0x44, "f12": 0x45,
+	"printscreen": 0x46, "scrolllock": 0x47, "pause": 0x48, "insert": 0x49,
+	"home": 0x4a, "pageup": 0x4b, "delete": 0x4c, "end": 0x4d, "pagedown": 0x4e,
+	"right": 0x4f, "left": 0x50, "down": 0x51, "up": 0x52,
+	// 小键盘
+	"numlock": 0x53, "kp/": 0x54, "kp*": 0x55, "kp-": 0x56, "kp+": 0x57,
+	"kpenter": 0x58, "kp1": 0x59, "kp2": 0x5a, "kp3": 0x5b, "kp4": 0x5c,
+	"kp5": 0x5d, "kp6": 0x5e, "kp7": 0x5f, "kp8": 0x60, "kp9": 0x61, "kp0": 0x62,
+	"kp.": 0x63, "nonus\\": 0x64,
+	// 修饰键
+	"application": 0x65, "power": 0x66, "kpequal": 0x67,
+	"f13": 0x68, "f14": 0x69, "f15": 0x6a, "f16": 0x6b, "f17": 0x6c, "f18": 0x6d,
+	"f19": 0x6e, "f20": 0x6f, "f21": 0x70, "f22": 0x71, "f23": 0x72, "f24": 0x73,
+	// 控制/系统
+	"control": 0xe0, "shift": 0xe1, "alt": 0xe2, "gui": 0xe3, // 左侧
+	"rcontrol": 0xe4, "rshift": 0xe5, "ralt": 0xe6, "rgui": 0xe7, // 右侧
+	// 媒体/系统控制（键盘页 usage，多数主机忽略；真正的媒体键见 consumerKeyMap 中的 media_*）
+	"mute": 0x7f, "volumeup": 0x80, "volumedown": 0x81,
+	// 国际键（部分键盘支持）
+	"intl1": 0x87, "intl2": 0x88, "intl3": 0x89, "intl4": 0x8a, "intl5": 0x8b,
+	"intl6": 0x8c, "intl7": 0x8d, "intl8": 0x8e, "intl9": 0x8f,
+}
+
+// keyAliases 按键别名到标准按键名的映射，所有驱动和接口共用
+var keyAliases = map[string]string{
+	// 修饰键
+	"ctrl": "control", "ctl": "control", "lctrl": "control", "lcontrol": "control",
+	"rctrl": "rcontrol", "rctl": "rcontrol",
+	"lshift": "shift",
+	"option": "alt", "opt": "alt", "lalt": "alt",
+	"altgr": "ralt", "roption": "ralt",
+	"cmd": "gui", "command": "gui", "win": "gui", "windows": "gui", "super": "gui", "meta": "gui", "lgui": "gui",
+	"rcmd": "rgui", "rwin": "rgui", "rsuper": "rgui", "rmeta": "rgui",
+	// 控制键
+	"return": "enter", "escape": "esc", "spacebar": "space",
+	"del": "delete", "ins": "insert", "pgup": "pageup", "pgdn": "pagedown",
+	"caps": "capslock", "prtsc": "printscreen", "pausebreak": "pause", "menu": "application",
+	// 方向键（浏览器 KeyboardEvent.key 的写法）
+	"arrowup": "up", "arrowdown": "down", "arrowleft": "left", "arrowright": "right",
+}
+
+// 按键类型
+const (
+	KeyTypeKeyboard = "keyboard" // 键盘页普通按键
+	KeyTypeModifier = "modifier" // 修饰键
+	KeyTypeMedia    = "media"    // Consumer 页媒体键
+)
+
+// KeyInfo 标准按键及其别名
+type KeyInfo struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Usage   uint16   `json:"usage"` // 键盘页 keycode 或 Consumer 页 usage
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// CanonicalKey 返回按键名的标准写法：转为小写并解析别名，未知按键返回小写形式
+func CanonicalKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if canonical, ok := keyAliases[key]; ok {
+		return canonical
+	}
+	return key
+}
+
+// KeyAliases 返回别名到标准按键名的映射
+func KeyAliases() map[string]string {
+	aliases := make(map[string]string, len(keyAliases))
+	for alias, canonical := range keyAliases {
+		aliases[alias] = canonical
+	}
+	return aliases
+}
+
+// Keys 返回所有标准按键（键盘按键和媒体键）及其别名，按名称排序
+func Keys() []KeyInfo {
+	aliases := make(map[string][]string)
+	for alias, canonical := range keyAliases {
+		aliases[canonical] = append(aliases[canonical], alias)
+	}
+
+	keys := make([]KeyInfo, 0, len(keyMap)+len(consumerKeyMap))
+	for name, keycode := range keyMap {
+		info := KeyInfo{Name: name, Type: KeyTypeKeyboard, Usage: uint16(keycode), Aliases: aliases[name]}
+		if hid.IsModifier(keycode) {
+			info.Type = KeyTypeModifier
+		}
+		keys = append(keys, info)
+	}
+	for name, usage := range consumerKeyMap {
+		keys = append(keys, KeyInfo{Name: name, Type: KeyTypeMedia, Usage: usage, Aliases: aliases[name]})
+	}
+	for _, info := range keys {
+		sort.Strings(info.Aliases)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys
+}
+
+var (
+	keyNamesOnce sync.Once
+	keyNames     map[byte]string
+)
+
+// KeyName 返回 keycode 对应的标准按键名
+func KeyName(keycode byte) (string, bool) {
+	keyNamesOnce.Do(func() {
+		keyNames = make(map[byte]string, len(keyMap))
+		for name, code := range keyMap {
+			keyNames[code] = name
+		}
+	})
+	name, ok := keyNames[keycode]
+	return name, ok
+}
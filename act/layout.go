@@ -0,0 +1,294 @@
+package act
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultLayoutName 默认主机键盘布局
+const DefaultLayoutName = "us"
+
+// Layout 主机键盘布局：描述主机在该布局下每个字符需要发送的按键序列
+type Layout struct {
+	Name  string
+	chars map[rune][]KeyStroke // 字符 -> 按键序列（死键字符为多个按键）
+}
+
+// Strokes 返回输入指定字符所需的按键序列
+func (l *Layout) Strokes(char rune) ([]KeyStroke, bool) {
+	strokes, ok := l.chars[char]
+	return strokes, ok
+}
+
+// TextToKeyStrokes 将文本转换为按键组合序列，并返回无法输入的字符
+func (l *Layout) TextToKeyStrokes(text string) ([]KeyStroke, []rune) {
+	return l.TextToKeyStrokesWith(text, nil)
+}
+
+// TextToKeyStrokesWith 将文本转换为按键组合序列
+// 布局无法直接输入的字符交给 fallback 策略处理，fallback 为 nil 时视为无法输入
+func (l *Layout) TextToKeyStrokesWith(text string, fallback UnicodeStrategy) ([]KeyStroke, []rune) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var strokes []KeyStroke
+	var unsupported []rune
+	for _, char := range text {
+		seq, ok := l.Strokes(char)
+		if !ok && fallback != nil {
+			seq, ok = fallback.Strokes(char, l)
+		}
+		if !ok {
+			unsupported = append(unsupported, char)
+			continue
+		}
+		strokes = append(strokes, seq...)
+	}
+	return strokes, unsupported
+}
+
+// layoutSpec 布局定义
+//
+// Keys 以按键名为键，值为该键在各层输出的字符：
+// 第 1 个字符为直接按下，第 2 个为 shift，第 3 个为 AltGr（右 alt），
+// 空格表示该层无字符。字母键未定义时默认输出小写/大写字母。
+// Dead 列出其中属于死键的字符，死键与后续字符组合出带重音的字符。
+// Chars 直接指定字符的按键序列，优先级最高。
+type layoutSpec struct {
+	Name  string                 `json:"name"`
+	Base  string                 `json:"base,omitempty"`
+	Keys  map[string]string      `json:"keys,omitempty"`
+	Dead  string                 `json:"dead,omitempty"`
+	Chars map[string][]KeyStroke `json:"chars,omitempty"`
+}
+
+// layoutLevelModifiers 各层对应的修饰键
+var layoutLevelModifiers = [][]string{
+	nil,
+	{"shift"},
+	{"ralt"},
+}
+
+// layoutKeyOrder 生成布局时遍历按键的顺序（决定同一字符多个位置时的优先级）
+var layoutKeyOrder = strings.Fields(
+	"a b c d e f g h i j k l m n o p q r s t u v w x y z " +
+		"1 2 3 4 5 6 7 8 9 0 ` - = [ ] \\ ; ' , . / nonus# nonus\\ intl1 intl3")
+
+// deadKeyCompose 死键组合表：死键字符 -> 基础字符与组合结果成对排列
+var deadKeyCompose = map[rune]string{
+	'^': "aâeêiîoôuûAÂEÊIÎOÔUÛ",
+	'´': "aáeéiíoóuúyýAÁEÉIÍOÓUÚYÝ",
+	'`': "aàeèiìoòuùAÀEÈIÌOÒUÙ",
+	'¨': "aäeëiïoöuüyÿAÄEËIÏOÖUÜ",
+	'~': "aãnñoõAÃNÑOÕ",
+}
+
+// build 根据布局定义生成布局
+func (s *layoutSpec) build() (*Layout, error) {
+	keys := make(map[string]string)
+	dead := s.Dead
+	var chars map[string][]KeyStroke
+	if s.Base != "" {
+		base, ok := layoutSpecs[strings.ToLower(s.Base)]
+		if !ok {
+			return nil, fmt.Errorf("未知的基础布局: %s", s.Base)
+		}
+		for key, levels := range base.Keys {
+			keys[key] = levels
+		}
+		dead = base.Dead + dead
+		chars = base.Chars
+	}
+	for key, levels := range s.Keys {
+		keys[key] = levels
+	}
+
+	layout := &Layout{Name: strings.ToLower(s.Name), chars: make(map[rune][]KeyStroke)}
+	deadStrokes := make(map[rune]KeyStroke)
+
+	for level, modifiers := range layoutLevelModifiers {
+		for _, key := range layoutKeyOrder {
+			levels, ok := keys[key]
+			if !ok {
+				if len(key) != 1 || key[0] < 'a' || key[0] > 'z' {
+					continue
+				}
+				levels = key + strings.ToUpper(key)
+			}
+			runes := []rune(levels)
+			if level >= len(runes) || runes[level] == ' ' {
+				continue
+			}
+			if _, ok := keyMap[key]; !ok {
+				return nil, fmt.Errorf("布局 %s 使用了未知按键: %s", s.Name, key)
+			}
+			char := runes[level]
+			stroke := KeyStroke{Key: key, Modifiers: modifiers}
+			if level < 2 && isCapsKey(runes) {
+				stroke.capsAffected = true
+			}
+			if strings.ContainsRune(dead, char) {
+				if _, exists := deadStrokes[char]; !exists {
+					deadStrokes[char] = stroke
+				}
+				continue
+			}
+			if _, exists := layout.chars[char]; !exists {
+				layout.chars[char] = []KeyStroke{stroke}
+			}
+		}
+	}
+
+	layout.chars[' '] = []KeyStroke{{Key: "space"}}
+	layout.chars['\n'] = []KeyStroke{{Key: "enter"}}
+	layout.chars['\t'] = []KeyStroke{{Key: "tab"}}
+
+	// 死键：组合出带重音的字符，死键 + 空格输出死键字符本身
+	for deadChar, deadStroke := range deadStrokes {
+		if _, exists := layout.chars[deadChar]; !exists {
+			layout.chars[deadChar] = []KeyStroke{deadStroke, {Key: "space"}}
+		}
+		pairs := []rune(deadKeyCompose[deadChar])
+		for i := 0; i+1 < len(pairs); i += 2 {
+			baseSeq, ok := layout.chars[pairs[i]]
+			if !ok {
+				continue
+			}
+			if _, exists := layout.chars[pairs[i+1]]; exists {
+				continue
+			}
+			seq := append([]KeyStroke{deadStroke}, baseSeq...)
+			layout.chars[pairs[i+1]] = seq
+		}
+	}
+
+	for _, explicit := range []map[string][]KeyStroke{chars, s.Chars} {
+		for str, seq := range explicit {
+			runes := []rune(str)
+			if len(runes) != 1 {
+				return nil, fmt.Errorf("布局 %s 的字符定义必须是单个字符: %q", s.Name, str)
+			}
+			for i, stroke := range seq {
+				// 布局文件中的按键名可以使用别名
+				stroke.Key = CanonicalKey(stroke.Key)
+				for j, modifier := range stroke.Modifiers {
+					stroke.Modifiers[j] = CanonicalKey(modifier)
+				}
+				seq[i] = stroke
+				if _, ok := keyMap[stroke.Key]; !ok {
+					return nil, fmt.Errorf("布局 %s 使用了未知按键: %s", s.Name, stroke.Key)
+				}
+			}
+			layout.chars[runes[0]] = seq
+		}
+	}
+
+	return layout, nil
+}
+
+// isCapsKey 判断 Caps Lock 是否影响该按键：直接按下和 shift 层是同一字母的小写和大写
+// 与按键位置无关，如法式布局 ; 键上的 m/M、德式布局 [ 键上的 ü/Ü
+func isCapsKey(levels []rune) bool {
+	if len(levels) < 2 || !unicode.IsLetter(levels[0]) || levels[0] == levels[1] {
+		return false
+	}
+	return unicode.ToUpper(levels[0]) == levels[1] && unicode.ToLower(levels[1]) == levels[0]
+}
+
+var (
+	layoutsMu sync.RWMutex
+	layouts   = make(map[string]*Layout)
+)
+
+func init() {
+	for _, spec := range layoutSpecs {
+		layout, err := spec.build()
+		if err != nil {
+			panic(fmt.Sprintf("内置布局 %s 定义错误: %v", spec.Name, err))
+		}
+		layouts[layout.Name] = layout
+	}
+}
+
+// RegisterLayout 注册布局（同名布局会被覆盖）
+func RegisterLayout(layout *Layout) {
+	layoutsMu.Lock()
+	defer layoutsMu.Unlock()
+	layouts[layout.Name] = layout
+}
+
+// GetLayout 按名称获取布局，名称为空时返回默认布局
+func GetLayout(name string) (*Layout, error) {
+	if name == "" {
+		name = DefaultLayoutName
+	}
+	layoutsMu.RLock()
+	defer layoutsMu.RUnlock()
+	layout, ok := layouts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的键盘布局: %s", name)
+	}
+	return layout, nil
+}
+
+// LayoutNames 返回已注册的布局名称列表
+func LayoutNames() []string {
+	layoutsMu.RLock()
+	defer layoutsMu.RUnlock()
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadLayoutFile 从 JSON 文件加载布局并注册
+func LoadLayoutFile(path string) (*Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取布局文件失败: %v", err)
+	}
+	var spec layoutSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("解析布局文件 %s 失败: %v", path, err)
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	layout, err := spec.build()
+	if err != nil {
+		return nil, err
+	}
+	RegisterLayout(layout)
+	return layout, nil
+}
+
+// LoadLayoutDir 加载目录下所有 .json 布局文件
+func LoadLayoutDir(dir string) ([]*Layout, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var loaded []*Layout
+	for _, path := range paths {
+		layout, err := LoadLayoutFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, layout)
+	}
+	return loaded, nil
+}
+
+// ResolveLayout 解析布局参数：以 .json 结尾时视为布局文件路径，否则按名称查找
+func ResolveLayout(nameOrPath string) (*Layout, error) {
+	if strings.HasSuffix(strings.ToLower(nameOrPath), ".json") {
+		return LoadLayoutFile(nameOrPath)
+	}
+	return GetLayout(nameOrPath)
+}
@@ -0,0 +1,105 @@
+package act
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// strokeNames 把按键序列格式化为 "shift+a" 形式，down/up 动作附加在末尾
+func strokeNames(strokes []KeyStroke) []string {
+	names := make([]string, 0, len(strokes))
+	for _, stroke := range strokes {
+		keys := append(append([]string{}, stroke.Modifiers...), stroke.Key)
+		name := strings.Join(keys, ChordSeparator)
+		if stroke.Action != "" {
+			name += ":" + stroke.Action
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestTextToKeyStrokes(t *testing.T) {
+	tests := []struct {
+		layout      string
+		text        string
+		want        []string
+		unsupported string
+	}{
+		{"us", "aZ@\r\n", []string{"a", "shift+z", "shift+2", "enter"}, ""},
+		{"us", "\t\\|~", []string{"tab", "\\", "shift+\\", "shift+`"}, ""},
+		{"us", "é€", []string{}, "é€"},
+		{"uk", "@\"£#", []string{"shift+'", "shift+2", "shift+3", "nonus#"}, ""},
+		{"de", "yz", []string{"z", "y"}, ""},
+		{"de", "@€é", []string{"ralt+q", "ralt+e", "=", "e"}, ""},
+		{"fr", "aqz1&", []string{"q", "a", "w", "shift+1", "1"}, ""},
+		{"fr", "éñ", []string{"2", "ralt+2", "n"}, ""},
+		{"jp", "@\"\\", []string{"[", "shift+2", "intl1"}, ""},
+	}
+	for _, tt := range tests {
+		layout, err := GetLayout(tt.layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		strokes, unsupported := layout.TextToKeyStrokesWith(tt.text, nil)
+		if got := strokeNames(strokes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: 按键序列 %v，期望 %v", tt.layout, tt.text, got, tt.want)
+		}
+		if string(unsupported) != tt.unsupported {
+			t.Errorf("%s %q: 无法输入 %q，期望 %q", tt.layout, tt.text, string(unsupported), tt.unsupported)
+		}
+	}
+}
+
+func TestTextToKeyStrokesFallback(t *testing.T) {
+	us, _ := GetLayout("us")
+	linux, err := GetUnicodeStrategy(UnicodeLinux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strokes, unsupported := us.TextToKeyStrokesWith("aé", linux)
+	want := []string{"a", "control+shift+u", "e", "9", "space"}
+	if got := strokeNames(strokes); !reflect.DeepEqual(got, want) || len(unsupported) != 0 {
+		t.Errorf("linux 策略: 按键序列 %v（无法输入 %q），期望 %v", got, string(unsupported), want)
+	}
+
+	windows, _ := GetUnicodeStrategy(UnicodeWindows)
+	strokes, _ = us.TextToKeyStrokesWith("é", windows)
+	want = []string{"alt:down", "kp0", "kp2", "kp3", "kp3", "alt:up"}
+	if got := strokeNames(strokes); !reflect.DeepEqual(got, want) {
+		t.Errorf("windows 策略: 按键序列 %v，期望 %v", got, want)
+	}
+}
+
+// TestLayoutRoundTrip 每个内置布局输入的按键按同一布局还原后与原文一致
+func TestLayoutRoundTrip(t *testing.T) {
+	texts := map[string]string{
+		"us": "The quick brown fox jumps over the lazy dog! 0123456789 ~`@#$%^&*()_+-=[]{}\\|;:'\",.<>/?\n",
+		"uk": "The quick brown fox £5 @home #1 \"quoted\" ~\\|\n",
+		"de": "Größe: 10€ @ zürich; ÄÖÜ äöü ß {[]} é è\n",
+		"fr": "Voilà: l'été à Paris coûte 10€ @ 5% (ç) ñ\n",
+		"jp": "Hello, World! @home [x] {y} _z_ \\100\n",
+	}
+	for _, name := range LayoutNames() {
+		text, ok := texts[name]
+		if !ok {
+			continue
+		}
+		layout, err := GetLayout(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, unsupported := layout.TextToKeyStrokes(text); len(unsupported) > 0 {
+			t.Errorf("%s: 无法输入 %q", name, string(unsupported))
+			continue
+		}
+		driver := NewVirtualDriver(layout)
+		if err := driver.Type(text); err != nil {
+			t.Fatalf("%s: Type: %v", name, err)
+		}
+		if got := driver.RenderedText(); got != text {
+			t.Errorf("%s: 主机看到 %q，期望 %q", name, got, text)
+		}
+	}
+}
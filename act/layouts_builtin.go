@@ -0,0 +1,65 @@
+package act
+
+// layoutSpecs 内置主机键盘布局
+//
+// 每个按键的字符依次为：直接按下、shift、AltGr，空格表示该层无字符
+var layoutSpecs = map[string]*layoutSpec{
+	// 美式 QWERTY
+	"us": {
+		Name: "us",
+		Keys: map[string]string{
+			"`": "`~", "1": "1!", "2": "2@", "3": "3#", "4": "4$", "5": "5%",
+			"6": "6^", "7": "7&", "8": "8*", "9": "9(", "0": "0)", "-": "-_", "=": "=+",
+			"[": "[{", "]": "]}", "\\": "\\|", ";": ";:", "'": "'\"",
+			",": ",<", ".": ".>", "/": "/?",
+		},
+	},
+
+	// 英式 QWERTY
+	"uk": {
+		Name: "uk",
+		Keys: map[string]string{
+			"`": "`¬¦", "1": "1!", "2": "2\"", "3": "3£", "4": "4$€", "5": "5%",
+			"6": "6^", "7": "7&", "8": "8*", "9": "9(", "0": "0)", "-": "-_", "=": "=+",
+			"[": "[{", "]": "]}", ";": ";:", "'": "'@", "nonus#": "#~", "nonus\\": "\\|",
+			",": ",<", ".": ".>", "/": "/?",
+		},
+	},
+
+	// 德式 QWERTZ
+	"de": {
+		Name: "de",
+		Keys: map[string]string{
+			"`": "^°", "1": "1!", "2": "2\"²", "3": "3§³", "4": "4$", "5": "5%",
+			"6": "6&", "7": "7/{", "8": "8([", "9": "9)]", "0": "0=}", "-": "ß?\\", "=": "´`",
+			"q": "qQ@", "e": "eE€", "y": "zZ", "z": "yY", "m": "mMµ",
+			"[": "üÜ", "]": "+*~", ";": "öÖ", "'": "äÄ", "nonus#": "#'", "nonus\\": "<>|",
+			",": ",;", ".": ".:", "/": "-_",
+		},
+		Dead: "^´`",
+	},
+
+	// 法式 AZERTY
+	"fr": {
+		Name: "fr",
+		Keys: map[string]string{
+			"`": "²", "1": "&1", "2": "é2~", "3": "\"3#", "4": "'4{", "5": "(5[",
+			"6": "-6|", "7": "è7`", "8": "_8\\", "9": "ç9^", "0": "à0@", "-": ")°]", "=": "=+}",
+			"q": "aA", "w": "zZ", "e": "eE€", "a": "qQ", "z": "wW", "m": ",?",
+			"[": "^¨", "]": "$£¤", ";": "mM", "'": "ù%", "nonus#": "*µ", "nonus\\": "<>",
+			",": ";.", ".": ":/", "/": "!§",
+		},
+		Dead: "~`^¨",
+	},
+
+	// 日式 JIS
+	"jp": {
+		Name: "jp",
+		Keys: map[string]string{
+			"1": "1!", "2": "2\"", "3": "3#", "4": "4$", "5": "5%",
+			"6": "6&", "7": "7'", "8": "8(", "9": "9)", "0": "0", "-": "-=", "=": "^~",
+			"[": "@`", "]": "[{", ";": ";+", "'": ":*", "nonus#": "]}",
+			",": ",<", ".": ".>", "/": "/?", "intl1": "\\_", "intl3": "¥|",
+		},
+	},
+}
@@ -0,0 +1,47 @@
+package act
+
+import "time"
+
+// 键盘 LED 输出报文各位（HID LED 页 0x01-0x05）
+const (
+	ledNumLock    = 1 << 0
+	ledCapsLock   = 1 << 1
+	ledScrollLock = 1 << 2
+	ledCompose    = 1 << 3
+	ledKana       = 1 << 4
+)
+
+// LEDState 主机设置的键盘锁定状态
+type LEDState struct {
+	NumLock    bool      `json:"num_lock"`
+	CapsLock   bool      `json:"caps_lock"`
+	ScrollLock bool      `json:"scroll_lock"`
+	Compose    bool      `json:"compose"`
+	Kana       bool      `json:"kana"`
+	Raw        byte      `json:"raw"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// LEDStateReporter 可读取主机键盘 LED 状态的驱动（可选能力）
+type LEDStateReporter interface {
+	// LEDState 返回最近一次收到的 LED 状态，尚未收到主机报文时 ok 为 false
+	LEDState() (state LEDState, ok bool)
+}
+
+// parseLEDReport 解析 LED 输出报文
+// boot 协议下报文只有 1 个字节；带报告 ID 的报文取最后一个字节
+func parseLEDReport(report []byte) (LEDState, bool) {
+	if len(report) == 0 {
+		return LEDState{}, false
+	}
+	raw := report[len(report)-1]
+	return LEDState{
+		NumLock:    raw&ledNumLock != 0,
+		CapsLock:   raw&ledCapsLock != 0,
+		ScrollLock: raw&ledScrollLock != 0,
+		Compose:    raw&ledCompose != 0,
+		Kana:       raw&ledKana != 0,
+		Raw:        raw,
+		UpdatedAt:  time.Now(),
+	}, true
+}
@@ -2,47 +2,107 @@ package act
 
 import (
 	"fmt"
-	"os"
-	"strings"
+	"log"
+	"pi-keyboard/hid"
 	"sync"
 	"time"
 )
 
 // LinuxOTGDriver Linux OTG 键盘驱动实现
 type LinuxOTGDriver struct {
-	outputFile string
-	pressedKey string
-	mu         sync.Mutex
+	outputFile  string
+	device      *HIDDevice           // 持久打开的键盘 HID 设备
+	consumer    *HIDDevice           // 持久打开的 Consumer Control（媒体键）HID 设备，未启用媒体键时为 nil
+	encoder     *hid.KeyboardEncoder // 键盘报文编码器，与 gadget 报告描述符使用相同的特性集合
+	layout      *Layout              // 主机键盘布局，用于 Type
+	unicode     UnicodeStrategy      // 布局无法输入的字符的输入策略，nil 表示不启用
+	capsLock    string               // 主机 Caps Lock 开启时 Type 的补偿方式
+	pressedKeys []string             // 当前按住的按键（含媒体键），按按下顺序排列
+	mu          sync.Mutex
+	sendMu      sync.Mutex // 串行化报文的构造和写入，并发按键时最后写入的报文总是反映最新的按键状态
+
+	consumerUsed bool // 是否发送过媒体键报文（关闭时需要释放）
+
+	protocol byte          // 主机选择的 HID 协议，默认报告协议（受 mu 保护）
+	stop     chan struct{} // 关闭时停止协议文件轮询
+	stopOnce sync.Once
+	host     *HostMonitor // USB 主机连接状态（UDC sysfs）
+
+	// 主机 LED 状态（由设备读协程更新）
+	ledMu    sync.RWMutex
+	leds     LEDState
+	ledKnown bool
 }
 
 // NewLinuxOTGDriver 创建 Linux OTG 驱动实例
 func NewLinuxOTGDriver(outputFile string) *LinuxOTGDriver {
+	return NewLinuxOTGDriverWithConfig(&DriverConfig{OutputFile: outputFile})
+}
+
+// NewLinuxOTGDriverWithConfig 按驱动配置创建 Linux OTG 驱动实例
+func NewLinuxOTGDriverWithConfig(config *DriverConfig) *LinuxOTGDriver {
+	outputFile := config.OutputFile
 	if outputFile == "" {
 		outputFile = "/dev/hidg0"
 	}
-	return &LinuxOTGDriver{
+	features := config.hidFeatures()
+	layout, _ := GetLayout(DefaultLayoutName)
+	d := &LinuxOTGDriver{
 		outputFile: outputFile,
+		layout:     layout,
+		capsLock:   CapsLockShift,
+		encoder:    hid.NewKeyboardEncoder(features),
+		protocol:   hid.ProtocolReport,
+		stop:       make(chan struct{}),
+		host:       NewHostMonitor(config.UDCRoot, config.UDC),
+	}
+	deviceConfig := HIDDeviceConfig{
+		Path:         outputFile,
+		FileSink:     config.FileSink,
+		WriteTimeout: config.WriteTimeout,
+	}
+	if features.LEDs {
+		deviceConfig.OnOutput = d.handleOutputReport
 	}
+	d.device = NewHIDDevice(deviceConfig)
+	if features.Consumer {
+		consumerFile := config.ConsumerFile
+		if consumerFile == "" {
+			consumerFile = DefaultHIDFile(features, hid.KindConsumer)
+		}
+		d.consumer = NewHIDDevice(HIDDeviceConfig{
+			Path:         consumerFile,
+			FileSink:     config.FileSink,
+			WriteTimeout: config.WriteTimeout,
+		})
+	}
+	if config.ProtocolFile != "" {
+		go watchProtocolFile(config.ProtocolFile, d.stop, d.applyProtocol)
+	} else if d.encoder.NKRO() {
+		log.Printf("[OTG] NKRO 模式未指定协议文件: f_hid 不上报 SET_PROTOCOL，主机请求 boot 协议（BIOS/UEFI）时不会自动回退，需通过 /protocol 切换")
+	}
+	return d
+}
+
+// SetLayout 设置 Type 使用的主机键盘布局
+func (d *LinuxOTGDriver) SetLayout(layout *Layout) {
+	d.layout = layout
 }
 
 // Press 按下并释放按键，持续指定时间（原子操作）
 func (d *LinuxOTGDriver) Press(key string, duration time.Duration) error {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if !d.IsKeySupported(key) {
 		return fmt.Errorf("不支持的按键: %s", key)
 	}
 
 	// 按下按键
-	d.mu.Lock()
-	d.pressedKey = key
-	d.mu.Unlock()
+	d.addPressedKey(key)
 
-	if err := d.sendHIDReport(); err != nil {
+	if err := d.sendReportFor(key); err != nil {
 		// 如果按下失败，确保清理状态
-		d.mu.Lock()
-		d.pressedKey = ""
-		d.mu.Unlock()
-		d.sendHIDReport() // 尝试发送释放报文
+		d.removePressedKey(key)
+		d.sendReportFor(key) // 尝试发送释放报文
 		return err
 	}
 
@@ -50,65 +110,147 @@ func (d *LinuxOTGDriver) Press(key string, duration time.Duration) error {
 	time.Sleep(duration)
 
 	// 释放按键
-	d.mu.Lock()
-	d.pressedKey = ""
-	d.mu.Unlock()
+	d.removePressedKey(key)
 
-	return d.sendHIDReport()
+	return d.sendReportFor(key)
 }
 
 // KeyDown 按下按键（不释放）
 func (d *LinuxOTGDriver) KeyDown(key string) error {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if !d.IsKeySupported(key) {
 		return fmt.Errorf("不支持的按键: %s", key)
 	}
-	d.mu.Lock()
-	d.pressedKey = key
-	d.mu.Unlock()
-	return d.sendHIDReport()
+	d.addPressedKey(key)
+	return d.sendReportFor(key)
 }
 
-// KeyUp 释放按键
+// KeyUp 释放按键（只释放指定按键，其它按住的键保持不变）
 func (d *LinuxOTGDriver) KeyUp(key string) error {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if !d.IsKeySupported(key) {
 		return fmt.Errorf("不支持的按键: %s", key)
 	}
-	d.mu.Lock()
-	d.pressedKey = ""
-	d.mu.Unlock()
-	return d.sendHIDReport()
+	d.removePressedKey(key)
+	return d.sendReportFor(key)
 }
 
-// Type 输入字符串
+// SetUnicodeStrategy 设置布局无法输入的字符的输入策略
+func (d *LinuxOTGDriver) SetUnicodeStrategy(strategy UnicodeStrategy) {
+	d.unicode = strategy
+}
+
+// SetCapsLockMode 设置主机 Caps Lock 开启时 Type 的补偿方式
+func (d *LinuxOTGDriver) SetCapsLockMode(mode string) {
+	d.capsLock = mode
+}
+
+// Type 输入字符串（按主机键盘布局处理大小写、符号、修饰键和死键，主机 Caps Lock 开启时按补偿方式调整）
 func (d *LinuxOTGDriver) Type(text string) error {
-	for _, char := range text {
-		key := strings.ToLower(string(char))
-		if d.IsKeySupported(key) {
-			if err := d.Press(key, 50*time.Millisecond); err != nil {
-				return fmt.Errorf("输入字符 %c 失败: %v", char, err)
-			}
-			// 字符间间隔
-			time.Sleep(10 * time.Millisecond)
+	strokes, unsupported := d.layout.TextToKeyStrokesWith(text, d.unicode)
+	if capsOn, known := d.LEDState(); known && capsOn.CapsLock {
+		strokes = compensateCapsLock(strokes, d.capsLock)
+	}
+	for _, stroke := range strokes {
+		if err := d.runStroke(stroke, 50*time.Millisecond); err != nil {
+			return fmt.Errorf("输入按键 %s 失败: %v", stroke.Key, err)
 		}
+		// 字符间间隔
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(unsupported) > 0 {
+		return &UnsupportedCharsError{Chars: unsupported}
 	}
 	return nil
 }
 
-// IsKeySupported 检查是否支持指定按键
+// runStroke 执行单个按键组合
+func (d *LinuxOTGDriver) runStroke(stroke KeyStroke, duration time.Duration) error {
+	switch stroke.Action {
+	case StrokeDown:
+		return d.KeyDown(stroke.Key)
+	case StrokeUp:
+		return d.KeyUp(stroke.Key)
+	default:
+		return d.pressStroke(stroke, duration)
+	}
+}
+
+// pressStroke 在同一份报文中按下修饰键和主按键，持续指定时间后一起释放
+func (d *LinuxOTGDriver) pressStroke(stroke KeyStroke, duration time.Duration) error {
+	return d.pressKeys(append(append([]string{}, stroke.Modifiers...), stroke.Key), duration)
+}
+
+// PressChord 在同一份报文中按下组合键的所有按键，持续指定时间后在同一份报文中释放
+// 含媒体键的组合键需要两个 HID 设备，退化为逐个按下
+func (d *LinuxOTGDriver) PressChord(keys []string, duration time.Duration) error {
+	canonical := make([]string, len(keys))
+	consumer := false
+	for i, key := range keys {
+		canonical[i] = CanonicalKey(key)
+		if !d.IsKeySupported(canonical[i]) {
+			return fmt.Errorf("不支持的按键: %s", key)
+		}
+		consumer = consumer || isConsumerKey(canonical[i])
+	}
+	if consumer {
+		return pressChordSequential(d, canonical, duration)
+	}
+	return d.pressKeys(canonical, duration)
+}
+
+// pressKeys 在同一份报文中按下多个键盘按键，持续指定时间后一起释放
+func (d *LinuxOTGDriver) pressKeys(keys []string, duration time.Duration) error {
+	for _, key := range keys {
+		d.addPressedKey(key)
+	}
+	release := func() error {
+		for _, key := range keys {
+			d.removePressedKey(key)
+		}
+		return d.sendHIDReport()
+	}
+
+	if err := d.sendHIDReport(); err != nil {
+		release()
+		return err
+	}
+	time.Sleep(duration)
+	return release()
+}
+
+// IsKeySupported 检查是否支持指定按键（键盘按键和媒体键）
 func (d *LinuxOTGDriver) IsKeySupported(key string) bool {
-	_, ok := keyMap[strings.ToLower(key)]
-	return ok
+	key = CanonicalKey(key)
+	_, ok := keyMap[key]
+	return ok || (d.consumer != nil && isConsumerKey(key))
 }
 
 // Close 关闭驱动，释放资源
 func (d *LinuxOTGDriver) Close() error {
 	// 确保释放所有按键
 	d.mu.Lock()
-	d.pressedKey = ""
+	d.pressedKeys = nil
+	consumerUsed := d.consumerUsed
 	d.mu.Unlock()
-	return d.sendHIDReport()
+	err := d.sendHIDReport()
+	if consumerUsed {
+		if cerr := d.sendConsumerReport(); err == nil {
+			err = cerr
+		}
+	}
+	d.stopOnce.Do(func() { close(d.stop) })
+	d.host.Close()
+	d.device.Close()
+	if d.consumer != nil {
+		d.consumer.Close()
+	}
+	return err
+}
+
+// DeviceHealth 返回 HID 设备健康状态
+func (d *LinuxOTGDriver) DeviceHealth() DeviceHealth {
+	return d.device.Health()
 }
 
 // GetDriverType 获取驱动类型
@@ -116,28 +258,137 @@ func (d *LinuxOTGDriver) GetDriverType() string {
 	return DriverTypeLinuxOTG
 }
 
-// sendHIDReport 发送 HID 报文到设备文件
-func (d *LinuxOTGDriver) sendHIDReport() error {
-	var report [8]byte
+// HostMonitor 返回 USB 主机连接状态监视器
+func (d *LinuxOTGDriver) HostMonitor() *HostMonitor {
+	return d.host
+}
 
+// HIDProtocol 返回当前 HID 协议以及报告协议下是否使用 NKRO 报文
+func (d *LinuxOTGDriver) HIDProtocol() (string, bool) {
 	d.mu.Lock()
-	if d.pressedKey != "" {
-		if keycode, ok := keyMap[d.pressedKey]; ok {
-			report[2] = keycode
-		}
+	defer d.mu.Unlock()
+	return hid.ProtocolName(d.protocol), d.encoder.NKRO()
+}
+
+// SetHIDProtocol 切换 HID 协议（boot/report）
+func (d *LinuxOTGDriver) SetHIDProtocol(protocol string) error {
+	p, err := hid.ParseProtocol(protocol)
+	if err != nil {
+		return err
 	}
+	d.applyProtocol(p)
+	return nil
+}
+
+// applyProtocol 切换协议，协议变化时按新格式重发当前按键状态
+func (d *LinuxOTGDriver) applyProtocol(protocol byte) {
+	d.mu.Lock()
+	changed := d.protocol != protocol
+	d.protocol = protocol
 	d.mu.Unlock()
+	if !changed {
+		return
+	}
 
-	file, err := os.OpenFile(d.outputFile, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		return fmt.Errorf("打开输出文件失败: %v", err)
+	log.Printf("[OTG] 主机切换到 %s 协议", hid.ProtocolName(protocol))
+	if err := d.sendHIDReport(); err != nil {
+		log.Printf("[OTG] 切换协议后重发报文失败: %v", err)
 	}
-	defer file.Close()
+}
 
-	_, err = file.Write(report[:])
-	if err != nil {
-		return fmt.Errorf("写入 hid 报文失败: %v", err)
+// LEDState 返回主机最近一次设置的 LED 状态
+func (d *LinuxOTGDriver) LEDState() (LEDState, bool) {
+	d.ledMu.RLock()
+	defer d.ledMu.RUnlock()
+	return d.leds, d.ledKnown
+}
+
+// handleOutputReport 处理主机发来的输出报文（LED 状态）
+func (d *LinuxOTGDriver) handleOutputReport(report []byte) {
+	state, ok := parseLEDReport(report)
+	if !ok {
+		return
 	}
+	d.ledMu.Lock()
+	changed := !d.ledKnown || d.leds.Raw != state.Raw
+	d.leds = state
+	d.ledKnown = true
+	d.ledMu.Unlock()
+	if changed {
+		log.Printf("[OTG] 主机 LED 状态: num=%v caps=%v scroll=%v",
+			state.NumLock, state.CapsLock, state.ScrollLock)
+	}
+}
 
-	return nil
+// addPressedKey 将按键加入按住集合（重复按下不会重复加入）
+func (d *LinuxOTGDriver) addPressedKey(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range d.pressedKeys {
+		if k == key {
+			return
+		}
+	}
+	d.pressedKeys = append(d.pressedKeys, key)
+}
+
+// removePressedKey 从按住集合中移除指定按键
+func (d *LinuxOTGDriver) removePressedKey(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, k := range d.pressedKeys {
+		if k == key {
+			d.pressedKeys = append(d.pressedKeys[:i], d.pressedKeys[i+1:]...)
+			return
+		}
+	}
+}
+
+// buildReport 根据当前按住的按键构造键盘报文
+func (d *LinuxOTGDriver) buildReport() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keycodes := make([]byte, 0, len(d.pressedKeys))
+	for _, key := range d.pressedKeys {
+		if keycode, ok := keyMap[key]; ok {
+			keycodes = append(keycodes, keycode)
+		}
+	}
+	return d.encoder.EncodeProtocol(keycodes, d.protocol)
+}
+
+// sendReportFor 发送按键所属设备的报文：媒体键发送 Consumer 报文，其它发送键盘报文
+func (d *LinuxOTGDriver) sendReportFor(key string) error {
+	if isConsumerKey(key) {
+		return d.sendConsumerReport()
+	}
+	return d.sendHIDReport()
+}
+
+// sendConsumerReport 发送 Consumer Control 报文，报文只能携带一个 usage，取最后按下的媒体键
+func (d *LinuxOTGDriver) sendConsumerReport() error {
+	if d.consumer == nil {
+		return fmt.Errorf("HID 特性未启用 %s", hid.FeatureConsumer)
+	}
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+	d.mu.Lock()
+	var usage uint16
+	for _, key := range d.pressedKeys {
+		if u, ok := consumerKeyMap[key]; ok {
+			usage = u
+		}
+	}
+	d.consumerUsed = true
+	d.mu.Unlock()
+
+	return d.consumer.Write(hid.EncodeConsumer(usage))
+}
+
+// sendHIDReport 通过持久打开的设备句柄发送 HID 报文
+func (d *LinuxOTGDriver) sendHIDReport() error {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+	return d.device.Write(d.buildReport())
 }
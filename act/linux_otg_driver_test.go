@@ -0,0 +1,58 @@
+package act
+
+import (
+	"path/filepath"
+	"pi-keyboard/hid"
+	"testing"
+)
+
+func newFileSinkDriver(t *testing.T) *LinuxOTGDriver {
+	t.Helper()
+	dir := t.TempDir()
+	driver, err := NewDriverFactory().CreateDriver(
+		WithDriverType(DriverTypeLinuxOTG),
+		WithOutputFile(filepath.Join(dir, "hidg0")),
+		WithConsumerFile(filepath.Join(dir, "hidg1")),
+		WithFileSink(true),
+		WithUDCRoot(filepath.Join(dir, "udc")),
+	)
+	if err != nil {
+		t.Fatalf("创建驱动失败: %v", err)
+	}
+	return driver.(*LinuxOTGDriver)
+}
+
+func TestLinuxOTGDriverCloseTwice(t *testing.T) {
+	driver := newFileSinkDriver(t)
+	if err := driver.KeyDown("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Close(); err != nil {
+		t.Fatalf("第一次 Close: %v", err)
+	}
+	// 第二次 Close 不应 panic
+	driver.Close()
+}
+
+// TestDefaultHIDFile 默认设备文件按 gadget up 创建 function 的顺序编号
+func TestDefaultHIDFile(t *testing.T) {
+	tests := []struct {
+		features string
+		kind     string
+		want     string
+	}{
+		{"boot,leds,consumer,mouse", hid.KindConsumer, "/dev/hidg1"},
+		{"boot,leds,consumer,mouse", hid.KindMouse, "/dev/hidg2"},
+		{"boot,leds,mouse", hid.KindMouse, "/dev/hidg1"},
+		{"boot,leds", hid.KindMouse, ""},
+	}
+	for _, tt := range tests {
+		features, err := hid.ParseFeatures(tt.features)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DefaultHIDFile(features, tt.kind); got != tt.want {
+			t.Errorf("DefaultHIDFile(%s, %s) = %q，期望 %q", tt.features, tt.kind, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package act
+
+import (
+	"fmt"
+	"pi-keyboard/hid"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinuxOTGMouseDriver Linux OTG 鼠标驱动实现
+type LinuxOTGMouseDriver struct {
+	outputFile string
+	device     *HIDDevice
+	buttons    byte // 当前按住的按钮位图
+	mu         sync.Mutex
+}
+
+// NewLinuxOTGMouseDriver 按驱动配置创建 Linux OTG 鼠标驱动实例
+func NewLinuxOTGMouseDriver(config *DriverConfig) *LinuxOTGMouseDriver {
+	outputFile := config.MouseFile
+	if outputFile == "" {
+		outputFile = DefaultHIDFile(config.hidFeatures(), hid.KindMouse)
+	}
+	return &LinuxOTGMouseDriver{
+		outputFile: outputFile,
+		device: NewHIDDevice(HIDDeviceConfig{
+			Path:         outputFile,
+			FileSink:     config.FileSink,
+			WriteTimeout: config.WriteTimeout,
+		}),
+	}
+}
+
+// Move 相对移动指针，超出单个报文范围（±127）时拆分为多个报文，距离不超过 ±MouseMaxDelta
+func (d *LinuxOTGMouseDriver) Move(dx, dy int) error {
+	if !deltaInRange(dx) || !deltaInRange(dy) {
+		return fmt.Errorf("相对移动距离超出范围 ±%d: (%d, %d)", MouseMaxDelta, dx, dy)
+	}
+	for dx != 0 || dy != 0 {
+		stepX, stepY := clampInt8(dx), clampInt8(dy)
+		if err := d.sendRelative(stepX, stepY, 0, 0); err != nil {
+			return err
+		}
+		dx -= int(stepX)
+		dy -= int(stepY)
+	}
+	return nil
+}
+
+// MoveTo 移动指针到绝对位置
+func (d *LinuxOTGMouseDriver) MoveTo(x, y int) error {
+	if x < 0 || x > MouseAbsMax || y < 0 || y > MouseAbsMax {
+		return fmt.Errorf("绝对坐标超出范围 0-%d: (%d, %d)", MouseAbsMax, x, y)
+	}
+	d.mu.Lock()
+	buttons := d.buttons
+	d.mu.Unlock()
+
+	return d.device.Write(hid.EncodeMouseAbsolute(buttons, uint16(x), uint16(y), 0, 0))
+}
+
+// Click 按下并释放按钮，持续指定时间
+func (d *LinuxOTGMouseDriver) Click(button string, duration time.Duration) error {
+	if err := d.ButtonDown(button); err != nil {
+		d.ButtonUp(button)
+		return err
+	}
+	time.Sleep(duration)
+	return d.ButtonUp(button)
+}
+
+// ButtonDown 按下按钮（不释放）
+func (d *LinuxOTGMouseDriver) ButtonDown(button string) error {
+	bit, err := d.buttonBit(button)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.buttons |= bit
+	d.mu.Unlock()
+	return d.sendRelative(0, 0, 0, 0)
+}
+
+// ButtonUp 释放按钮
+func (d *LinuxOTGMouseDriver) ButtonUp(button string) error {
+	bit, err := d.buttonBit(button)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.buttons &^= bit
+	d.mu.Unlock()
+	return d.sendRelative(0, 0, 0, 0)
+}
+
+// Scroll 滚动滚轮，超出单个报文范围时拆分为多个报文，距离不超过 ±MouseMaxDelta
+func (d *LinuxOTGMouseDriver) Scroll(vertical, horizontal int) error {
+	if !deltaInRange(vertical) || !deltaInRange(horizontal) {
+		return fmt.Errorf("滚动距离超出范围 ±%d: (%d, %d)", MouseMaxDelta, vertical, horizontal)
+	}
+	for vertical != 0 || horizontal != 0 {
+		wheel, pan := clampInt8(vertical), clampInt8(horizontal)
+		if err := d.sendRelative(0, 0, wheel, pan); err != nil {
+			return err
+		}
+		vertical -= int(wheel)
+		horizontal -= int(pan)
+	}
+	return nil
+}
+
+// IsButtonSupported 检查是否支持指定按钮
+func (d *LinuxOTGMouseDriver) IsButtonSupported(button string) bool {
+	_, ok := mouseButtons[strings.ToLower(button)]
+	return ok
+}
+
+// Close 关闭驱动，释放所有按钮
+func (d *LinuxOTGMouseDriver) Close() error {
+	d.mu.Lock()
+	held := d.buttons
+	d.buttons = 0
+	d.mu.Unlock()
+
+	var err error
+	if held != 0 {
+		err = d.sendRelative(0, 0, 0, 0)
+	}
+	d.device.Close()
+	return err
+}
+
+// GetDriverType 获取驱动类型
+func (d *LinuxOTGMouseDriver) GetDriverType() string {
+	return DriverTypeLinuxOTG
+}
+
+// DeviceHealth 返回鼠标 HID 设备健康状态
+func (d *LinuxOTGMouseDriver) DeviceHealth() DeviceHealth {
+	return d.device.Health()
+}
+
+// buttonBit 查找按钮对应的位
+func (d *LinuxOTGMouseDriver) buttonBit(button string) (byte, error) {
+	bit, ok := mouseButtons[strings.ToLower(button)]
+	if !ok {
+		return 0, fmt.Errorf("不支持的鼠标按钮: %s", button)
+	}
+	return bit, nil
+}
+
+// sendRelative 发送相对移动报文（同时携带当前按钮状态）
+func (d *LinuxOTGMouseDriver) sendRelative(dx, dy, wheel, pan int8) error {
+	d.mu.Lock()
+	buttons := d.buttons
+	d.mu.Unlock()
+
+	return d.device.Write(hid.EncodeMouseRelative(buttons, dx, dy, wheel, pan))
+}
+
+// clampInt8 将数值限制在单个报文可表示的范围内（-127 到 127）
+func clampInt8(v int) int8 {
+	switch {
+	case v > 127:
+		return 127
+	case v < -127:
+		return -127
+	default:
+		return int8(v)
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 // MacOSDriver Mac OS 键盘驱动实现
 type MacOSDriver struct {
-	// Mac OS 特殊按键映射
+	// 标准按键名到 Mac OS 按键名的映射
 	macKeyMap map[string]string
 }
 
@@ -25,8 +25,8 @@ func NewMacOSDriver() *MacOSDriver {
 			"space":     "space",
 			"tab":       "tab",
 			"shift":     "shift",
-			"cmd":       "command",
-			"ctrl":      "control",
+			"gui":       "command",
+			"control":   "control",
 			"alt":       "option",
 			"up":        "up arrow",
 			"down":      "down arrow",
@@ -38,6 +38,7 @@ func NewMacOSDriver() *MacOSDriver {
 
 // Press 按下并释放按键，支持真实的持续时间
 func (d *MacOSDriver) Press(key string, duration time.Duration) error {
+	key = CanonicalKey(key)
 	if !d.IsKeySupported(key) {
 		return fmt.Errorf("不支持的按键: %s", key)
 	}
@@ -51,6 +52,48 @@ func (d *MacOSDriver) Press(key string, duration time.Duration) error {
 	return d.pressWithDuration(key, duration)
 }
 
+// macChordModifiers 修饰键（translateKey 之后的名称）对应的 AppleScript using 子句
+var macChordModifiers = map[string]string{
+	"shift":   "shift down",
+	"control": "control down",
+	"option":  "option down",
+	"command": "command down",
+}
+
+// PressChord 用一条 AppleScript 按下组合键（keystroke ... using {...}），由系统保证原子性
+// 除最后一个按键外都须是修饰键，否则逐个按下；AppleScript 的组合键是瞬时的，不支持持续时间
+func (d *MacOSDriver) PressChord(keys []string, duration time.Duration) error {
+	for _, key := range keys {
+		if !d.IsKeySupported(key) {
+			return fmt.Errorf("不支持的按键: %s", key)
+		}
+	}
+	if len(keys) == 1 {
+		return d.Press(keys[0], duration)
+	}
+
+	var using []string
+	for _, key := range keys[:len(keys)-1] {
+		modifier, ok := macChordModifiers[d.translateKey(key)]
+		if !ok {
+			return pressChordSequential(d, keys, duration)
+		}
+		using = append(using, modifier)
+	}
+
+	key := CanonicalKey(keys[len(keys)-1])
+	action := "key code " + d.getKeyCode(d.translateKey(key))
+	if len(key) == 1 && ((key >= "a" && key <= "z") || (key >= "0" && key <= "9")) {
+		action = fmt.Sprintf(`keystroke "%s"`, key)
+	}
+	script := fmt.Sprintf(`tell application "System Events" to %s using {%s}`, action, strings.Join(using, ", "))
+	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+		log.Printf("[MACOS] 组合键执行失败 - 按键: %s, 错误: %v", strings.Join(keys, ChordSeparator), err)
+		return fmt.Errorf("执行 applescript 失败: %v", err)
+	}
+	return nil
+}
+
 // KeyDown 按下按键（不释放）
 func (d *MacOSDriver) KeyDown(key string) error {
 	return d.keyDown(key)
@@ -221,9 +264,9 @@ func (d *MacOSDriver) Type(text string) error {
 	return nil
 }
 
-// IsKeySupported 检查是否支持指定按键（简化版）
+// IsKeySupported 检查是否支持指定按键（简化版），按键名按标准名和别名解析
 func (d *MacOSDriver) IsKeySupported(key string) bool {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 
 	// 检查是否为单个字符
 	if len(key) == 1 {
@@ -252,9 +295,9 @@ func (d *MacOSDriver) GetDriverType() string {
 	return DriverTypeMacOS
 }
 
-// translateKey 将通用按键名转换为 Mac OS 按键名
+// translateKey 将按键名（标准名或别名）转换为 Mac OS 按键名
 func (d *MacOSDriver) translateKey(key string) string {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if macKey, ok := d.macKeyMap[key]; ok {
 		return macKey
 	}
@@ -0,0 +1,168 @@
+package act
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 单次点击请求的上限，避免一个请求长时间占用鼠标
+const (
+	mouseMaxClickCount    = 10
+	mouseMaxClickDuration = 10 * time.Second
+)
+
+// Mouse 鼠标服务，提供 /mouse/* HTTP 接口
+type Mouse struct {
+	driver MouseDriver
+}
+
+// NewMouse 创建鼠标服务
+func NewMouse(driver MouseDriver) *Mouse {
+	log.Printf("[MOUSE] 鼠标服务启动 - 驱动: %s", driver.GetDriverType())
+	return &Mouse{driver: driver}
+}
+
+// MoveHandler 指针移动接口
+// 相对移动：/mouse/move?dx=10&dy=-5；绝对定位：/mouse/move?x=16384&y=16384（0-32767）
+func (m *Mouse) MoveHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Has("x") || query.Has("y") {
+		x, errX := strconv.Atoi(query.Get("x"))
+		y, errY := strconv.Atoi(query.Get("y"))
+		if errX != nil || errY != nil {
+			http.Error(w, "绝对定位需要整数参数 x 和 y", 400)
+			return
+		}
+		if x < 0 || x > MouseAbsMax || y < 0 || y > MouseAbsMax {
+			http.Error(w, fmt.Sprintf("绝对坐标超出范围 0-%d", MouseAbsMax), 400)
+			return
+		}
+		m.respond(w, "move_to", m.driver.MoveTo(x, y))
+		return
+	}
+
+	dx, errX := intParam(query.Get("dx"))
+	dy, errY := intParam(query.Get("dy"))
+	if errX != nil || errY != nil {
+		http.Error(w, "相对移动参数 dx/dy 必须是整数", 400)
+		return
+	}
+	if !deltaInRange(dx) || !deltaInRange(dy) {
+		http.Error(w, fmt.Sprintf("相对移动距离超出范围 ±%d", MouseMaxDelta), 400)
+		return
+	}
+	m.respond(w, "move", m.driver.Move(dx, dy))
+}
+
+// ClickHandler 点击接口：/mouse/click?button=left&duration=50&count=2
+func (m *Mouse) ClickHandler(w http.ResponseWriter, r *http.Request) {
+	button, ok := m.buttonParam(w, r)
+	if !ok {
+		return
+	}
+
+	duration := 50 * time.Millisecond
+	if ms, err := strconv.Atoi(r.URL.Query().Get("duration")); err == nil && ms > 0 {
+		duration = time.Duration(ms) * time.Millisecond
+	}
+	if duration > mouseMaxClickDuration {
+		http.Error(w, fmt.Sprintf("duration 不能超过 %dms", mouseMaxClickDuration.Milliseconds()), 400)
+		return
+	}
+	count := 1
+	if n, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && n > 0 {
+		count = n
+	}
+	if count > mouseMaxClickCount {
+		http.Error(w, fmt.Sprintf("count 不能超过 %d", mouseMaxClickCount), 400)
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(duration) // 多击之间的间隔
+		}
+		if err := m.driver.Click(button, duration); err != nil {
+			m.respond(w, "click", err)
+			return
+		}
+	}
+	m.respond(w, "click", nil)
+}
+
+// ButtonDownHandler 按钮按下接口：/mouse/down?button=left
+func (m *Mouse) ButtonDownHandler(w http.ResponseWriter, r *http.Request) {
+	if button, ok := m.buttonParam(w, r); ok {
+		m.respond(w, "down", m.driver.ButtonDown(button))
+	}
+}
+
+// ButtonUpHandler 按钮释放接口：/mouse/up?button=left
+func (m *Mouse) ButtonUpHandler(w http.ResponseWriter, r *http.Request) {
+	if button, ok := m.buttonParam(w, r); ok {
+		m.respond(w, "up", m.driver.ButtonUp(button))
+	}
+}
+
+// ScrollHandler 滚轮接口：/mouse/scroll?dy=-3&dx=0（dy 正数向上，dx 正数向右）
+func (m *Mouse) ScrollHandler(w http.ResponseWriter, r *http.Request) {
+	dy, errY := intParam(r.URL.Query().Get("dy"))
+	dx, errX := intParam(r.URL.Query().Get("dx"))
+	if errX != nil || errY != nil {
+		http.Error(w, "滚动参数 dx/dy 必须是整数", 400)
+		return
+	}
+	if !deltaInRange(dx) || !deltaInRange(dy) {
+		http.Error(w, fmt.Sprintf("滚动距离超出范围 ±%d", MouseMaxDelta), 400)
+		return
+	}
+	m.respond(w, "scroll", m.driver.Scroll(dy, dx))
+}
+
+// Close 关闭鼠标服务
+func (m *Mouse) Close() error {
+	log.Printf("[MOUSE] 关闭鼠标服务")
+	return m.driver.Close()
+}
+
+// buttonParam 读取并校验 button 参数，默认 left
+func (m *Mouse) buttonParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	button := strings.ToLower(r.URL.Query().Get("button"))
+	if button == "" {
+		button = "left"
+	}
+	if !m.driver.IsButtonSupported(button) {
+		http.Error(w, "不支持的鼠标按钮: "+button, 400)
+		return "", false
+	}
+	return button, true
+}
+
+// respond 根据驱动执行结果返回响应
+func (m *Mouse) respond(w http.ResponseWriter, op string, err error) {
+	if err != nil {
+		log.Printf("[MOUSE] %s 失败: %v", op, err)
+		http.Error(w, "鼠标操作失败: "+err.Error(), driverErrorStatus(err))
+		return
+	}
+	io.WriteString(w, "ok")
+}
+
+// deltaInRange 检查相对移动或滚动距离是否在 ±MouseMaxDelta 以内
+func deltaInRange(delta int) bool {
+	return delta >= -MouseMaxDelta && delta <= MouseMaxDelta
+}
+
+// intParam 解析整数参数，为空时返回 0
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
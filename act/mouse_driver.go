@@ -0,0 +1,51 @@
+package act
+
+import (
+	"pi-keyboard/hid"
+	"time"
+)
+
+// MouseDriver 定义鼠标驱动的统一接口（与 KeyboardDriver 对应）
+type MouseDriver interface {
+	// Move 相对移动指针，每个方向的距离不超过 ±MouseMaxDelta
+	Move(dx, dy int) error
+
+	// MoveTo 移动指针到绝对位置，坐标范围 0-MouseAbsMax，(0,0) 为屏幕左上角
+	MoveTo(x, y int) error
+
+	// Click 按下并释放按钮，持续指定时间
+	Click(button string, duration time.Duration) error
+
+	// ButtonDown 按下按钮（不释放）
+	ButtonDown(button string) error
+
+	// ButtonUp 释放按钮
+	ButtonUp(button string) error
+
+	// Scroll 滚动滚轮，vertical 正数向上，horizontal 正数向右，距离不超过 ±MouseMaxDelta
+	Scroll(vertical, horizontal int) error
+
+	// IsButtonSupported 检查是否支持指定按钮
+	IsButtonSupported(button string) bool
+
+	// Close 关闭驱动，释放所有按钮
+	Close() error
+
+	// GetDriverType 获取驱动类型
+	GetDriverType() string
+}
+
+// MouseAbsMax 绝对坐标的最大值
+const MouseAbsMax = hid.MouseAbsMax
+
+// MouseMaxDelta 单次相对移动、滚动每个方向的最大距离（按 ±127 拆分后最多约 260 个报文）
+const MouseMaxDelta = 32767
+
+// mouseButtons 鼠标按钮位
+var mouseButtons = map[string]byte{
+	"left":    1 << 0,
+	"right":   1 << 1,
+	"middle":  1 << 2,
+	"back":    1 << 3,
+	"forward": 1 << 4,
+}
@@ -0,0 +1,53 @@
+package act
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMouseHandlersRejectUnboundedInput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hidg2")
+	driver := NewLinuxOTGMouseDriver(&DriverConfig{MouseFile: output, FileSink: true})
+	defer driver.Close()
+	mouse := &Mouse{driver: driver}
+
+	tests := []struct {
+		handler http.HandlerFunc
+		url     string
+		status  int
+	}{
+		{mouse.ClickHandler, "/mouse/click?count=2&duration=1", 200},
+		{mouse.ClickHandler, "/mouse/click?count=11", 400},
+		{mouse.ClickHandler, "/mouse/click?duration=10001", 400},
+		{mouse.MoveHandler, "/mouse/move?dx=300&dy=-300", 200},
+		{mouse.MoveHandler, "/mouse/move?dx=32768", 400},
+		{mouse.MoveHandler, "/mouse/move?dy=-1000000000", 400},
+		{mouse.ScrollHandler, "/mouse/scroll?dy=-3", 200},
+		{mouse.ScrollHandler, "/mouse/scroll?dx=99999999", 400},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: HTTP %d，期望 %d（%s）", tt.url, w.Code, tt.status, w.Body.String())
+		}
+	}
+
+	// 驱动同样拒绝超出范围的距离，不写入任何报文
+	before, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Move(MouseMaxDelta+1, 0); err == nil {
+		t.Error("Move 超出范围时未返回错误")
+	}
+	if err := driver.Scroll(0, -MouseMaxDelta-1); err == nil {
+		t.Error("Scroll 超出范围时未返回错误")
+	}
+	if after, _ := os.Stat(output); after.Size() != before.Size() {
+		t.Errorf("超出范围的请求写入了报文: %d -> %d 字节", before.Size(), after.Size())
+	}
+}
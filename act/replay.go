@@ -0,0 +1,228 @@
+package act
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 回放速度限制
+const (
+	MinReplaySpeed = 0.01
+	MaxReplaySpeed = 100
+)
+
+// maxReplayUpload 通过请求体上传的记录最大字节数
+const maxReplayUpload = 16 << 20
+
+// RecordedEvent 按键记录（key_record_<ts>.csv）中的一个按下/释放边沿
+type RecordedEvent struct {
+	Time     time.Time     `json:"time"`
+	Key      string        `json:"key"`
+	Action   string        `json:"action"`             // down 或 up
+	Duration time.Duration `json:"duration,omitempty"` // up 事件记录的按住时长
+	Line     int           `json:"line"`               // 在 CSV 中的行号
+}
+
+// ReplayOptions 回放参数
+type ReplayOptions struct {
+	Speed  float64       // 速度倍数，2 表示两倍速，0 视为 1
+	MaxGap time.Duration // 按速度换算后超过该值的间隔压缩为该值，0 表示不压缩
+}
+
+// ReplayResult 回放结果
+type ReplayResult struct {
+	Events   int           // 已发送的边沿数
+	Total    int           // 记录中的边沿数
+	Duration time.Duration // 实际耗时
+	Planned  time.Duration // 按速度和间隔压缩计算的时长
+	MaxLag   time.Duration // 边沿相对计划时间的最大延迟
+	Released []string      // 结束时仍按住、由回放释放的按键
+}
+
+// Validate 检查回放参数
+func (o ReplayOptions) Validate() error {
+	if o.Speed != 0 && (o.Speed < MinReplaySpeed || o.Speed > MaxReplaySpeed) {
+		return fmt.Errorf("回放速度须在 %v 到 %v 之间: %v", MinReplaySpeed, MaxReplaySpeed, o.Speed)
+	}
+	if o.MaxGap < 0 {
+		return fmt.Errorf("最大间隔不能为负数: %v", o.MaxGap)
+	}
+	return nil
+}
+
+// LoadRecord 读取按键记录文件
+func LoadRecord(path string) ([]RecordedEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRecord(file)
+}
+
+// ParseRecord 解析按键记录 CSV（time,key,action,duration_ms），表头可省略
+// 事件按时间排序（时间相同时保持原顺序），按键名解析为标准名
+func ParseRecord(r io.Reader) ([]RecordedEvent, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var events []RecordedEvent
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %v", line, err)
+		}
+		if line == 1 && len(row) > 0 && strings.EqualFold(strings.TrimSpace(row[0]), "time") {
+			continue
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("第 %d 行: 至少需要 time,key,action 三列", line)
+		}
+
+		event := RecordedEvent{Key: CanonicalKey(row[1]), Action: strings.ToLower(strings.TrimSpace(row[2])), Line: line}
+		if event.Time, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(row[0])); err != nil {
+			return nil, fmt.Errorf("第 %d 行: 时间格式无效: %s", line, row[0])
+		}
+		if event.Key == "" {
+			return nil, fmt.Errorf("第 %d 行: 按键为空", line)
+		}
+		if event.Action != StrokeDown && event.Action != StrokeUp {
+			return nil, fmt.Errorf("第 %d 行: 未知的动作: %s（只支持 down、up）", line, row[2])
+		}
+		if len(row) > 3 && strings.TrimSpace(row[3]) != "" {
+			ms, err := strconv.ParseInt(strings.TrimSpace(row[3]), 10, 64)
+			if err != nil || ms < 0 {
+				return nil, fmt.Errorf("第 %d 行: duration_ms 无效: %s", line, row[3])
+			}
+			event.Duration = time.Duration(ms) * time.Millisecond
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+// ValidateRecord 检查记录中的按键是否都被驱动支持，避免回放到一半才失败
+func ValidateRecord(events []RecordedEvent, driver KeyboardDriver) error {
+	for _, event := range events {
+		if !driver.IsKeySupported(event.Key) {
+			return fmt.Errorf("第 %d 行: 不支持的按键: %s", event.Line, event.Key)
+		}
+	}
+	return nil
+}
+
+// ReplaySchedule 计算每个边沿相对回放开始的发送时间：间隔按速度缩放后再按 MaxGap 压缩
+func ReplaySchedule(events []RecordedEvent, opts ReplayOptions) []time.Duration {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	offsets := make([]time.Duration, len(events))
+	for i := 1; i < len(events); i++ {
+		gap := time.Duration(float64(events[i].Time.Sub(events[i-1].Time)) / speed)
+		if opts.MaxGap > 0 && gap > opts.MaxGap {
+			gap = opts.MaxGap
+		}
+		offsets[i] = offsets[i-1] + gap
+	}
+	return offsets
+}
+
+// Replay 按记录的时间线通过驱动重放按下/释放边沿
+//
+// 每个边沿按相对回放开始的绝对时间发送，单个边沿的延迟不会累积到后续边沿。
+// ctx 取消或驱动出错时停止回放；无论如何结束，都会释放回放中按下且仍按住的按键。
+func Replay(ctx context.Context, driver KeyboardDriver, events []RecordedEvent, opts ReplayOptions) (ReplayResult, error) {
+	if err := opts.Validate(); err != nil {
+		return ReplayResult{}, err
+	}
+	if err := ValidateRecord(events, driver); err != nil {
+		return ReplayResult{}, err
+	}
+
+	offsets := ReplaySchedule(events, opts)
+	result := ReplayResult{Total: len(events)}
+	if len(offsets) > 0 {
+		result.Planned = offsets[len(offsets)-1]
+	}
+
+	held := make(map[string]bool)
+	var order []string // 按下顺序，释放时逆序
+	release := func() {
+		for i := len(order) - 1; i >= 0; i-- {
+			key := order[i]
+			if !held[key] {
+				continue
+			}
+			if err := driver.KeyUp(key); err != nil {
+				log.Printf("[REPLAY] 释放按键 %s 失败: %v", key, err)
+			}
+			delete(held, key)
+			result.Released = append(result.Released, key)
+		}
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	var err error
+	for i, event := range events {
+		if wait := time.Until(start.Add(offsets[i])); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		if lag := time.Since(start) - offsets[i]; lag > result.MaxLag {
+			result.MaxLag = lag
+		}
+
+		if event.Action == StrokeDown {
+			err = driver.KeyDown(event.Key)
+			if err == nil && !held[event.Key] {
+				held[event.Key] = true
+				order = append(order, event.Key)
+			}
+		} else {
+			err = driver.KeyUp(event.Key)
+			delete(held, event.Key)
+		}
+		if err != nil {
+			err = fmt.Errorf("第 %d 行: 按键 %s %s 失败: %w", event.Line, event.Key, event.Action, err)
+			break
+		}
+		result.Events++
+	}
+
+	release()
+	result.Duration = time.Since(start)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("[REPLAY] 回放中止: %v（%d/%d）", err, result.Events, result.Total)
+	}
+	return result, err
+}
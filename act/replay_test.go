@@ -0,0 +1,159 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const replayRecord = `time,key,action,duration_ms
+2024-01-01T10:00:00.100Z,a,up,100
+2024-01-01T10:00:00Z,ctrl,down,
+2024-01-01T10:00:00Z,A,down,
+`
+
+func TestParseRecord(t *testing.T) {
+	events, err := ParseRecord(strings.NewReader(replayRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按时间排序，时间相同时保持原顺序；按键名解析为标准名
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Key+":"+event.Action)
+	}
+	if want := []string{"control:down", "a:down", "a:up"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("事件 %v，期望 %v", got, want)
+	}
+	if events[2].Line != 2 || events[2].Duration != 100*time.Millisecond {
+		t.Errorf("up 事件: 行 %d，时长 %v", events[2].Line, events[2].Duration)
+	}
+
+	// 没有表头时第一行就是事件
+	noHeader := strings.SplitN(replayRecord, "\n", 2)[1]
+	if events, err := ParseRecord(strings.NewReader(noHeader)); err != nil || len(events) != 3 || events[2].Line != 1 {
+		t.Errorf("无表头记录: %v, %v", events, err)
+	}
+}
+
+func TestParseRecordErrors(t *testing.T) {
+	tests := []struct {
+		record string
+		want   string
+	}{
+		{"time,key,action\n2024-01-01T10:00:00Z,a,down\nyesterday,a,up\n", "第 3 行: 时间格式无效"},
+		{"2024-01-01T10:00:00Z,a\n", "第 1 行: 至少需要"},
+		{"2024-01-01T10:00:00Z,a,down\n2024-01-01T10:00:00Z,a,hold\n", "第 2 行: 未知的动作"},
+		{"2024-01-01T10:00:00Z,a,up,-5\n", "第 1 行: duration_ms 无效"},
+		{"2024-01-01T10:00:00Z,\"a,down\n", "第 1 行"},
+	}
+	for _, tt := range tests {
+		_, err := ParseRecord(strings.NewReader(tt.record))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%q: 错误 %v，期望以 %q 开头", tt.record, err, tt.want)
+		}
+	}
+}
+
+func TestReplaySchedule(t *testing.T) {
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	var events []RecordedEvent
+	for _, ms := range []int{0, 100, 300, 10300} {
+		events = append(events, RecordedEvent{Time: base.Add(time.Duration(ms) * time.Millisecond)})
+	}
+	ms := func(values ...int) []time.Duration {
+		offsets := make([]time.Duration, len(values))
+		for i, v := range values {
+			offsets[i] = time.Duration(v) * time.Millisecond
+		}
+		return offsets
+	}
+
+	tests := []struct {
+		opts ReplayOptions
+		want []time.Duration
+	}{
+		{ReplayOptions{}, ms(0, 100, 300, 10300)},
+		{ReplayOptions{Speed: 2}, ms(0, 50, 150, 5150)},
+		{ReplayOptions{Speed: 0.5}, ms(0, 200, 600, 20600)},
+		// 先按速度缩放，再压缩长间隔
+		{ReplayOptions{MaxGap: time.Second}, ms(0, 100, 300, 1300)},
+		{ReplayOptions{Speed: 2, MaxGap: 80 * time.Millisecond}, ms(0, 50, 130, 210)},
+	}
+	for _, tt := range tests {
+		if got := ReplaySchedule(events, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: %v，期望 %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// cancelOnKeyDriver 按下指定按键后取消回放
+type cancelOnKeyDriver struct {
+	*VirtualDriver
+	key    string
+	cancel context.CancelFunc
+}
+
+func (d *cancelOnKeyDriver) KeyDown(key string) error {
+	err := d.VirtualDriver.KeyDown(key)
+	if key == d.key {
+		d.cancel()
+	}
+	return err
+}
+
+func TestReplayCancelReleasesHeldKeys(t *testing.T) {
+	base := time.Now()
+	events := []RecordedEvent{
+		{Time: base, Key: "control", Action: StrokeDown, Line: 1},
+		{Time: base, Key: "shift", Action: StrokeDown, Line: 2},
+		{Time: base, Key: "t", Action: StrokeDown, Line: 3},
+		{Time: base.Add(time.Hour), Key: "t", Action: StrokeUp, Line: 4},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	driver := &cancelOnKeyDriver{VirtualDriver: NewVirtualDriver(nil), key: "t", cancel: cancel}
+
+	result, err := Replay(ctx, driver, events, ReplayOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Replay 返回 %v，期望 context.Canceled", err)
+	}
+	if result.Events != 3 || result.Total != 4 {
+		t.Errorf("已发送 %d/%d 个边沿，期望 3/4", result.Events, result.Total)
+	}
+	// 逆序释放仍按住的按键
+	want := []string{"t", "shift", "control"}
+	if !reflect.DeepEqual(result.Released, want) {
+		t.Errorf("Released = %v，期望 %v", result.Released, want)
+	}
+	var ups []string
+	for _, event := range driver.Events(0) {
+		if event.Action == VirtualUp {
+			ups = append(ups, event.Key)
+		}
+	}
+	if !reflect.DeepEqual(ups, want) {
+		t.Errorf("驱动收到的释放顺序 %v，期望 %v", ups, want)
+	}
+	if pressed := driver.PressedKeys(); len(pressed) != 0 {
+		t.Errorf("回放结束后仍按住 %v", pressed)
+	}
+}
+
+func TestReplayRejectsUnsupportedKey(t *testing.T) {
+	events := []RecordedEvent{
+		{Time: time.Now(), Key: "a", Action: StrokeDown, Line: 2},
+		{Time: time.Now(), Key: "nosuch", Action: StrokeDown, Line: 3},
+	}
+	driver := NewVirtualDriver(nil)
+	_, err := Replay(context.Background(), driver, events, ReplayOptions{})
+	if err == nil || !strings.HasPrefix(err.Error(), "第 3 行") {
+		t.Errorf("Replay 返回 %v，期望第 3 行的错误", err)
+	}
+	if len(driver.Events(0)) != 0 {
+		t.Error("记录无效时仍发送了按键")
+	}
+}
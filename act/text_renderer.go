@@ -0,0 +1,139 @@
+package act
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TextRenderer 按布局把按键还原为主机会看到的字符
+//
+// 虚拟驱动和报文解码器共用，与 Layout 使用同一份布局表。
+type TextRenderer struct {
+	single   map[string]rendered            // 单个按键组合 -> 字符
+	dead     map[string]map[string]rendered // 死键组合 -> 后续按键组合 -> 字符
+	deadChar map[string]rune                // 死键组合 -> 死键字符本身
+	pending  string                         // 已按下、等待组合的死键
+	capsLock bool
+	text     []rune
+}
+
+// rendered 渲染结果
+type rendered struct {
+	char         rune
+	capsAffected bool
+}
+
+// NewTextRenderer 根据布局建立按键到字符的反向映射
+func NewTextRenderer(layout *Layout) *TextRenderer {
+	r := &TextRenderer{
+		single:   make(map[string]rendered),
+		dead:     make(map[string]map[string]rendered),
+		deadChar: make(map[string]rune),
+	}
+	for char, strokes := range layout.chars {
+		switch {
+		case len(strokes) == 1:
+			id := strokeID(strokes[0].Key, strokes[0].Modifiers)
+			if existing, ok := r.single[id]; !ok || char < existing.char {
+				r.single[id] = rendered{char: char, capsAffected: strokes[0].capsAffected}
+			}
+		case len(strokes) == 2:
+			first, second := strokeID(strokes[0].Key, strokes[0].Modifiers), strokeID(strokes[1].Key, strokes[1].Modifiers)
+			if r.dead[first] == nil {
+				r.dead[first] = make(map[string]rendered)
+			}
+			r.dead[first][second] = rendered{char: char, capsAffected: strokes[1].capsAffected}
+			if strokes[1].Key == "space" && len(strokes[1].Modifiers) == 0 {
+				r.deadChar[first] = char
+			}
+		}
+	}
+	return r
+}
+
+// Press 渲染一次按键，held 为同时按住的按键（含修饰键）
+func (r *TextRenderer) Press(key string, held []string) {
+	var modifiers []string
+	for _, k := range held {
+		switch k {
+		case "shift", "rshift":
+			modifiers = append(modifiers, "shift")
+		case "ralt":
+			modifiers = append(modifiers, "ralt")
+		case "control", "rcontrol", "alt", "gui", "rgui":
+			// 快捷键不产生字符
+			return
+		}
+	}
+
+	switch key {
+	case "capslock":
+		r.capsLock = !r.capsLock
+		return
+	case "backspace":
+		r.pending = ""
+		if len(r.text) > 0 {
+			r.text = r.text[:len(r.text)-1]
+		}
+		return
+	}
+
+	id := strokeID(key, modifiers)
+	if r.pending != "" {
+		dead := r.pending
+		r.pending = ""
+		if out, ok := r.dead[dead][id]; ok {
+			r.emit(out)
+			return
+		}
+		// 无法组合：主机输出死键字符本身和后续字符
+		if char, ok := r.deadChar[dead]; ok {
+			r.text = append(r.text, char)
+		}
+	}
+	if _, ok := r.dead[id]; ok {
+		r.pending = id
+		return
+	}
+	if out, ok := r.single[id]; ok {
+		r.emit(out)
+	}
+}
+
+// Text 返回已渲染的文本
+func (r *TextRenderer) Text() string {
+	return string(r.text)
+}
+
+// CapsLock 返回渲染器跟踪的 Caps Lock 状态
+func (r *TextRenderer) CapsLock() bool {
+	return r.capsLock
+}
+
+// emit 输出字符，Caps Lock 开启时反转受影响字符的大小写
+func (r *TextRenderer) emit(out rendered) {
+	char := out.char
+	if r.capsLock && out.capsAffected {
+		if unicode.IsUpper(char) {
+			char = unicode.ToLower(char)
+		} else {
+			char = unicode.ToUpper(char)
+		}
+	}
+	r.text = append(r.text, char)
+}
+
+// strokeID 按键组合的标识：按键名 + 排序去重后的修饰键
+func strokeID(key string, modifiers []string) string {
+	set := make(map[string]bool)
+	for _, modifier := range modifiers {
+		set[modifier] = true
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(append(names, key), "+")
+}
@@ -0,0 +1,240 @@
+package act
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// UnicodeStrategy 布局无法直接输入的字符的输入策略
+// 每种策略利用主机端的输入机制（Unicode 输入、Alt 码、输入法等）输入任意字符
+type UnicodeStrategy interface {
+	// Name 策略名称
+	Name() string
+
+	// Strokes 返回输入指定字符所需的按键序列，layout 为主机键盘布局
+	Strokes(char rune, layout *Layout) ([]KeyStroke, bool)
+}
+
+// Unicode 输入策略名称
+const (
+	UnicodeLinux   = "linux"   // IBus/GTK: Ctrl+Shift+U + 十六进制 + 空格
+	UnicodeWindows = "windows" // 按住 Alt + 小键盘十进制码
+	UnicodeMacOS   = "macos"   // Unicode Hex Input: 按住 Option + 4 位十六进制
+	UnicodePinyin  = "pinyin"  // 输入拼音 + 选字键，由主机中文输入法完成转换
+)
+
+var unicodeStrategies = map[string]UnicodeStrategy{
+	UnicodeLinux:   linuxUnicodeStrategy{},
+	UnicodeWindows: windowsUnicodeStrategy{},
+	UnicodeMacOS:   macOSUnicodeStrategy{},
+	UnicodePinyin:  &PinyinStrategy{SelectKey: "space"},
+}
+
+// GetUnicodeStrategy 按名称获取 Unicode 输入策略，名称为空时返回 nil（不启用）
+func GetUnicodeStrategy(name string) (UnicodeStrategy, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	strategy, ok := unicodeStrategies[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的 Unicode 输入策略: %s", name)
+	}
+	return strategy, nil
+}
+
+// UnicodeStrategyNames 返回可用的 Unicode 输入策略名称
+func UnicodeStrategyNames() []string {
+	names := make([]string, 0, len(unicodeStrategies))
+	for name := range unicodeStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layoutTextStrokes 按布局转换文本，任一字符无法输入时返回 false
+func layoutTextStrokes(layout *Layout, text string) ([]KeyStroke, bool) {
+	strokes, unsupported := layout.TextToKeyStrokes(text)
+	return strokes, len(unsupported) == 0
+}
+
+// linuxUnicodeStrategy IBus/GTK 的 Ctrl+Shift+U 十六进制输入
+type linuxUnicodeStrategy struct{}
+
+func (linuxUnicodeStrategy) Name() string { return UnicodeLinux }
+
+func (linuxUnicodeStrategy) Strokes(char rune, layout *Layout) ([]KeyStroke, bool) {
+	digits, ok := layoutTextStrokes(layout, strconv.FormatInt(int64(char), 16))
+	if !ok {
+		return nil, false
+	}
+	strokes := []KeyStroke{{Key: "u", Modifiers: []string{"control", "shift"}}}
+	strokes = append(strokes, digits...)
+	return append(strokes, KeyStroke{Key: "space"}), true
+}
+
+// windowsUnicodeStrategy 按住 Alt 在小键盘输入十进制码
+// 小于 256 的码位加前导 0，其余直接输入十进制码位（适用于 RichEdit 类应用）
+type windowsUnicodeStrategy struct{}
+
+func (windowsUnicodeStrategy) Name() string { return UnicodeWindows }
+
+func (windowsUnicodeStrategy) Strokes(char rune, layout *Layout) ([]KeyStroke, bool) {
+	code := strconv.Itoa(int(char))
+	if char < 256 {
+		code = "0" + code
+	}
+	strokes := []KeyStroke{{Key: "alt", Action: StrokeDown}}
+	for _, digit := range code {
+		strokes = append(strokes, KeyStroke{Key: "kp" + string(digit)})
+	}
+	return append(strokes, KeyStroke{Key: "alt", Action: StrokeUp}), true
+}
+
+// macOSUnicodeStrategy macOS「Unicode 十六进制输入」输入源
+// 按住 Option 输入每个 UTF-16 码元的 4 位十六进制，该输入源按 QWERTY 解析按键
+type macOSUnicodeStrategy struct{}
+
+func (macOSUnicodeStrategy) Name() string { return UnicodeMacOS }
+
+func (macOSUnicodeStrategy) Strokes(char rune, layout *Layout) ([]KeyStroke, bool) {
+	units := []uint16{uint16(char)}
+	if char > 0xffff {
+		r1, r2 := utf16.EncodeRune(char)
+		units = []uint16{uint16(r1), uint16(r2)}
+	}
+	strokes := []KeyStroke{{Key: "alt", Action: StrokeDown}}
+	for _, unit := range units {
+		for _, digit := range fmt.Sprintf("%04x", unit) {
+			strokes = append(strokes, KeyStroke{Key: string(digit)})
+		}
+	}
+	return append(strokes, KeyStroke{Key: "alt", Action: StrokeUp}), true
+}
+
+// PinyinStrategy 输入汉字拼音并按选字键，由主机中文输入法上屏
+type PinyinStrategy struct {
+	SelectKey string // 选字键，默认 space（首选候选词）
+}
+
+func (p *PinyinStrategy) Name() string { return UnicodePinyin }
+
+func (p *PinyinStrategy) Strokes(char rune, layout *Layout) ([]KeyStroke, bool) {
+	pinyin, ok := LookupPinyin(char)
+	if !ok {
+		return nil, false
+	}
+	strokes, ok := layoutTextStrokes(layout, pinyin)
+	if !ok {
+		return nil, false
+	}
+	selectKey := p.SelectKey
+	if selectKey == "" {
+		selectKey = "space"
+	}
+	return append(strokes, KeyStroke{Key: selectKey}), true
+}
+
+var (
+	pinyinMu   sync.RWMutex
+	pinyinDict = buildPinyinDict()
+)
+
+// LookupPinyin 查找汉字的拼音（不带声调）
+func LookupPinyin(char rune) (string, bool) {
+	pinyinMu.RLock()
+	defer pinyinMu.RUnlock()
+	pinyin, ok := pinyinDict[char]
+	return pinyin, ok
+}
+
+// LoadPinyinDict 从文件加载拼音词典，每行格式为「汉字 拼音」，# 开头为注释
+// 加载的条目会覆盖内置词典中的同名字符
+func LoadPinyinDict(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("打开拼音词典失败: %v", err)
+	}
+	defer file.Close()
+
+	entries := make(map[rune]string)
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		runes := []rune(fields[0])
+		if len(fields) != 2 || len(runes) != 1 {
+			return 0, fmt.Errorf("拼音词典第 %d 行格式错误: %s", line, text)
+		}
+		entries[runes[0]] = strings.ToLower(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("读取拼音词典失败: %v", err)
+	}
+
+	pinyinMu.Lock()
+	defer pinyinMu.Unlock()
+	for char, pinyin := range entries {
+		pinyinDict[char] = pinyin
+	}
+	return len(entries), nil
+}
+
+// buildPinyinDict 构造内置常用汉字拼音词典
+func buildPinyinDict() map[rune]string {
+	dict := make(map[rune]string)
+	for pinyin, chars := range commonPinyin {
+		for _, char := range chars {
+			dict[char] = pinyin
+		}
+	}
+	return dict
+}
+
+// commonPinyin 内置常用汉字（拼音 -> 汉字，每个汉字只出现一次，多音字取最常用读音）
+// 更完整的词典可通过 LoadPinyinDict 加载
+var commonPinyin = map[string]string{
+	"a": "啊阿", "ai": "爱哀", "an": "安按暗岸", "ba": "把八吧爸", "bai": "白百败", "ban": "办半班板般",
+	"bang": "帮", "bao": "报保包宝", "bei": "被北备背杯", "ben": "本", "bi": "比必笔", "bian": "边变便",
+	"biao": "表标", "bie": "别", "bing": "并病兵", "bu": "不部步布", "cai": "才菜材", "can": "参",
+	"ce": "策测", "ceng": "层曾", "chang": "长场常厂", "che": "车", "chen": "陈", "cheng": "成程城称",
+	"chi": "吃持", "chu": "出处除", "chuan": "传", "chuang": "创", "ci": "次此", "cong": "从",
+	"cuo": "错", "da": "大打达答", "dai": "带代", "dan": "但单", "dang": "当党", "dao": "到道导",
+	"de": "的得德", "deng": "等", "di": "地第低", "dian": "点电店", "ding": "定", "dong": "动东懂",
+	"dou": "都", "du": "度读", "duan": "段断", "dui": "对队", "duo": "多", "er": "而二儿",
+	"fa": "发法", "fan": "反饭", "fang": "方放房", "fei": "非飞费", "fen": "分份", "feng": "风",
+	"fu": "服复付", "gai": "该改", "gan": "感干", "gao": "高告", "ge": "个各哥", "gei": "给",
+	"gen": "跟根", "gong": "工公功共", "gou": "够", "gu": "古故", "guan": "关管", "guang": "光广",
+	"gui": "规", "guo": "国过", "hai": "还海孩", "han": "汉", "hao": "好号", "he": "和合何",
+	"hen": "很", "hou": "后候", "hu": "户护", "hua": "话化花", "huan": "换", "hui": "会回",
+	"huo": "或活火", "ji": "机几及记计级", "jia": "家加价", "jian": "见间件建", "jiang": "将讲",
+	"jiao": "叫教交", "jie": "界结接解", "jin": "进今金近", "jing": "经京", "jiu": "就九", "ju": "局举",
+	"jue": "觉决", "kai": "开", "kan": "看", "ke": "可科课", "kou": "口", "kuai": "快块", "lai": "来",
+	"lao": "老", "le": "了乐", "li": "里理力利", "lian": "连", "liang": "两量", "lu": "路",
+	"ma": "吗马妈", "mai": "买卖", "man": "满", "mei": "没每美", "men": "们门", "mi": "密",
+	"mian": "面", "min": "民", "ming": "名明", "mu": "目", "na": "那拿", "nan": "难南男", "ne": "呢",
+	"neng": "能", "ni": "你", "nian": "年", "nin": "您", "nv": "女", "peng": "朋", "pin": "品",
+	"qi": "起其期气", "qian": "前钱", "qing": "请情清", "qu": "去区取", "quan": "全", "ran": "然",
+	"ren": "人认任", "ri": "日", "ru": "如入", "san": "三", "shang": "上商", "shao": "少", "she": "设社",
+	"shei": "谁", "shen": "什身深", "sheng": "生声", "shi": "是时事十使市", "shou": "手收",
+	"shu": "书数", "shui": "水", "shuo": "说", "si": "四思死", "suo": "所", "ta": "他她它",
+	"tai": "太", "ti": "题体", "tian": "天", "tiao": "条", "tong": "同通", "tou": "头", "wai": "外",
+	"wan": "完万晚", "wang": "王网", "wei": "为位", "wen": "问文", "wo": "我", "wu": "五无物",
+	"xi": "西系喜", "xia": "下", "xian": "先现", "xiang": "想向", "xiao": "小校", "xie": "些写谢",
+	"xin": "新心信", "xing": "行性", "xue": "学", "yao": "要", "ye": "也业", "yi": "一以已意",
+	"yin": "因", "ying": "应", "yong": "用", "you": "有又", "yu": "与于", "yuan": "元员原",
+	"yue": "月", "zai": "在再", "zao": "早", "ze": "则", "zen": "怎", "zhan": "战", "zhang": "张",
+	"zhe": "这着", "zhen": "真", "zheng": "正", "zhi": "只知之", "zhong": "中种", "zhu": "主",
+	"zi": "子自字", "zong": "总", "zou": "走", "zui": "最", "zuo": "做作坐",
+}
@@ -0,0 +1,277 @@
+package act
+
+import (
+	"fmt"
+	"pi-keyboard/hid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// virtualMaxEvents 虚拟驱动保留的最大事件数，超出后丢弃最早的事件
+const virtualMaxEvents = 10000
+
+// 虚拟驱动事件类型
+const (
+	VirtualPress = "press"
+	VirtualDown  = "down"
+	VirtualUp    = "up"
+	VirtualType  = "type"
+)
+
+// VirtualEvent 虚拟驱动记录的一次调用
+type VirtualEvent struct {
+	Seq      int64     `json:"seq"`
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Key      string    `json:"key,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Duration int64     `json:"duration_ms,omitempty"`
+}
+
+// EventRecorder 记录按键事件并渲染主机可见文本的驱动（可选能力）
+type EventRecorder interface {
+	// Events 返回序号大于 since 的事件
+	Events(since int64) []VirtualEvent
+	// RenderedText 返回主机按当前布局会看到的文本
+	RenderedText() string
+	// PressedKeys 返回当前按住的按键
+	PressedKeys() []string
+	// Reset 清空事件和渲染的文本
+	Reset()
+}
+
+// VirtualDriver 内存中的虚拟键盘驱动，不访问任何硬件
+//
+// 记录每次 Press/KeyDown/KeyUp/Type 调用，并按主机键盘布局渲染主机会看到的文本，
+// 用于演练宏、CI 集成和没有硬件时的调试。
+type VirtualDriver struct {
+	layout   *Layout
+	unicode  UnicodeStrategy
+	capsLock string // 主机 Caps Lock 开启时 Type 的补偿方式
+
+	mu       sync.Mutex
+	events   []VirtualEvent
+	seq      int64
+	pressed  []string
+	renderer *TextRenderer
+}
+
+// NewVirtualDriver 创建虚拟驱动，layout 为 nil 时使用默认布局
+func NewVirtualDriver(layout *Layout) *VirtualDriver {
+	if layout == nil {
+		layout, _ = GetLayout(DefaultLayoutName)
+	}
+	return &VirtualDriver{
+		layout:   layout,
+		renderer: NewTextRenderer(layout),
+		capsLock: CapsLockShift,
+	}
+}
+
+// SetLayout 设置主机键盘布局，渲染文本按新布局进行
+func (d *VirtualDriver) SetLayout(layout *Layout) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.layout = layout
+	d.renderer = NewTextRenderer(layout)
+}
+
+// SetUnicodeStrategy 设置布局无法输入的字符的输入策略
+func (d *VirtualDriver) SetUnicodeStrategy(strategy UnicodeStrategy) {
+	d.unicode = strategy
+}
+
+// SetCapsLockMode 设置主机 Caps Lock 开启时 Type 的补偿方式
+func (d *VirtualDriver) SetCapsLockMode(mode string) {
+	d.capsLock = mode
+}
+
+// Press 按下并释放按键，与硬件驱动一样在持续时间内保持按下
+func (d *VirtualDriver) Press(key string, duration time.Duration) error {
+	key = CanonicalKey(key)
+	if !d.IsKeySupported(key) {
+		return fmt.Errorf("不支持的按键: %s", key)
+	}
+	d.mu.Lock()
+	d.record(VirtualEvent{Action: VirtualPress, Key: key, Duration: duration.Milliseconds()})
+	d.keyDown(key)
+	d.mu.Unlock()
+
+	time.Sleep(duration)
+
+	d.mu.Lock()
+	d.keyUp(key)
+	d.mu.Unlock()
+	return nil
+}
+
+// PressChord 按顺序按下组合键的所有按键，持续指定时间后逆序释放，记录为一次按键事件
+func (d *VirtualDriver) PressChord(keys []string, duration time.Duration) error {
+	canonical := make([]string, len(keys))
+	for i, key := range keys {
+		canonical[i] = CanonicalKey(key)
+		if !d.IsKeySupported(canonical[i]) {
+			return fmt.Errorf("不支持的按键: %s", key)
+		}
+	}
+	d.mu.Lock()
+	d.record(VirtualEvent{Action: VirtualPress, Key: strings.Join(canonical, ChordSeparator), Duration: duration.Milliseconds()})
+	for _, key := range canonical {
+		d.keyDown(key)
+	}
+	d.mu.Unlock()
+
+	time.Sleep(duration)
+
+	d.mu.Lock()
+	for i := len(canonical) - 1; i >= 0; i-- {
+		d.keyUp(canonical[i])
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// KeyDown 按下按键（不释放）
+func (d *VirtualDriver) KeyDown(key string) error {
+	key = CanonicalKey(key)
+	if !d.IsKeySupported(key) {
+		return fmt.Errorf("不支持的按键: %s", key)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.record(VirtualEvent{Action: VirtualDown, Key: key})
+	d.keyDown(key)
+	return nil
+}
+
+// KeyUp 释放按键
+func (d *VirtualDriver) KeyUp(key string) error {
+	key = CanonicalKey(key)
+	if !d.IsKeySupported(key) {
+		return fmt.Errorf("不支持的按键: %s", key)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.record(VirtualEvent{Action: VirtualUp, Key: key})
+	d.keyUp(key)
+	return nil
+}
+
+// Type 按布局输入文本，与 Linux OTG 驱动一样补偿已开启的 Caps Lock
+func (d *VirtualDriver) Type(text string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.record(VirtualEvent{Action: VirtualType, Text: text})
+
+	strokes, unsupported := d.layout.TextToKeyStrokesWith(text, d.unicode)
+	if d.renderer.CapsLock() {
+		strokes = compensateCapsLock(strokes, d.capsLock)
+	}
+	for _, stroke := range strokes {
+		switch stroke.Action {
+		case StrokeDown:
+			d.keyDown(stroke.Key)
+		case StrokeUp:
+			d.keyUp(stroke.Key)
+		default:
+			d.renderer.Press(stroke.Key, append(append([]string{}, d.pressed...), stroke.Modifiers...))
+		}
+	}
+	if len(unsupported) > 0 {
+		return &UnsupportedCharsError{Chars: unsupported}
+	}
+	return nil
+}
+
+// IsKeySupported 检查是否支持指定按键
+func (d *VirtualDriver) IsKeySupported(key string) bool {
+	key = CanonicalKey(key)
+	_, ok := keyMap[key]
+	return ok || isConsumerKey(key)
+}
+
+// Close 释放所有按键
+func (d *VirtualDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pressed = nil
+	return nil
+}
+
+// GetDriverType 获取驱动类型
+func (d *VirtualDriver) GetDriverType() string {
+	return DriverTypeVirtual
+}
+
+// LEDState 返回渲染器跟踪的 Caps Lock 状态
+func (d *VirtualDriver) LEDState() (LEDState, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return LEDState{CapsLock: d.renderer.CapsLock()}, true
+}
+
+// Events 返回序号大于 since 的事件
+func (d *VirtualDriver) Events(since int64) []VirtualEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := sort.Search(len(d.events), func(i int) bool { return d.events[i].Seq > since })
+	return append([]VirtualEvent{}, d.events[i:]...)
+}
+
+// RenderedText 返回主机会看到的文本
+func (d *VirtualDriver) RenderedText() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.renderer.Text()
+}
+
+// PressedKeys 返回当前按住的按键
+func (d *VirtualDriver) PressedKeys() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.pressed...)
+}
+
+// Reset 清空事件和渲染的文本（事件序号继续递增）
+func (d *VirtualDriver) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = nil
+	d.renderer = NewTextRenderer(d.layout)
+}
+
+// record 记录事件，调用方持有 mu
+func (d *VirtualDriver) record(event VirtualEvent) {
+	d.seq++
+	event.Seq = d.seq
+	event.Time = time.Now()
+	d.events = append(d.events, event)
+	if len(d.events) > virtualMaxEvents {
+		d.events = d.events[len(d.events)-virtualMaxEvents:]
+	}
+}
+
+// keyDown 按住按键，普通按键按下时渲染一次（不模拟自动重复），调用方持有 mu
+func (d *VirtualDriver) keyDown(key string) {
+	for _, k := range d.pressed {
+		if k == key {
+			return
+		}
+	}
+	if keycode, ok := keyMap[key]; !ok || !hid.IsModifier(keycode) {
+		d.renderer.Press(key, d.pressed)
+	}
+	d.pressed = append(d.pressed, key)
+}
+
+// keyUp 释放按键，调用方持有 mu
+func (d *VirtualDriver) keyUp(key string) {
+	for i, k := range d.pressed {
+		if k == key {
+			d.pressed = append(d.pressed[:i], d.pressed[i+1:]...)
+			return
+		}
+	}
+}
@@ -106,10 +106,10 @@ func NewWindowsDriver() *WindowsDriver {
 	return &WindowsDriver{
 		winKeyMap: map[string]string{
 			"left": "0x25", "up": "0x26", "right": "0x27", "down": "0x28",
-			"backspace": "0x08", "tab": "0x09", "enter": "0x0D", "shift": "0x10", "ctrl": "0x11", "alt": "0x12", "caps lock": "0x14", "esc": "0x1B", "space": "0x20", "page up": "0x21", "page down": "0x22", "end": "0x23", "home": "0x24", "insert": "0x2D", "delete": "0x2E",
+			"backspace": "0x08", "tab": "0x09", "enter": "0x0D", "shift": "0x10", "control": "0x11", "alt": "0x12", "capslock": "0x14", "esc": "0x1B", "space": "0x20", "pageup": "0x21", "pagedown": "0x22", "end": "0x23", "home": "0x24", "insert": "0x2D", "delete": "0x2E",
 			"0": "0x30", "1": "0x31", "2": "0x32", "3": "0x33", "4": "0x34", "5": "0x35", "6": "0x36", "7": "0x37", "8": "0x38", "9": "0x39",
 			"a": "0x41", "b": "0x42", "c": "0x43", "d": "0x44", "e": "0x45", "f": "0x46", "g": "0x47", "h": "0x48", "i": "0x49", "j": "0x4A", "k": "0x4B", "l": "0x4C", "m": "0x4D", "n": "0x4E", "o": "0x4F", "p": "0x50", "q": "0x51", "r": "0x52", "s": "0x53", "t": "0x54", "u": "0x55", "v": "0x56", "w": "0x57", "x": "0x58", "y": "0x59", "z": "0x5A",
-			"f1": "0x70", "f2": "0x71", "f3": "0x72", "f4": "0x73", "f5": "0x74", "f6": "0x75", "f7": "0x76", "f8": "0x77", "f9": "0x78", "f10": "0x79", "f11": "0x7A", "f12": "0x7B", "num lock": "0x90", "scroll lock": "0x91",
+			"f1": "0x70", "f2": "0x71", "f3": "0x72", "f4": "0x73", "f5": "0x74", "f6": "0x75", "f7": "0x76", "f8": "0x77", "f9": "0x78", "f10": "0x79", "f11": "0x7A", "f12": "0x7B", "numlock": "0x90", "scrolllock": "0x91", "gui": "0x5B", "rgui": "0x5C",
 			";": "0xBA", "=": "0xBB", ",": "0xBC", "-": "0xBD", ".": "0xBE", "/": "0xBF", "`": "0xC0", "[": "0xDB", "\\": "0xDC", "]": "0xDD", "'": "0xDE",
 		},
 		senderType: SenderPython,
@@ -132,6 +132,7 @@ func (d *WindowsDriver) SetSenderType(t SenderType) {
 
 // Press 按下并释放按键，支持持续时间
 func (d *WindowsDriver) Press(key string, duration time.Duration) error {
+	key = CanonicalKey(key)
 	if !d.IsKeySupported(key) {
 		return fmt.Errorf("不支持的按键: %s", key)
 	}
@@ -166,6 +167,33 @@ func (d *WindowsDriver) KeyUp(key string) error {
 	return d.sender.KeyUp(vk)
 }
 
+// PressChord 按下组合键的所有按键，持续指定时间后逆序释放
+// python 方案在同一个进程中发送全部按键事件，其它方案逐个按下
+func (d *WindowsDriver) PressChord(keys []string, duration time.Duration) error {
+	vks := make([]string, len(keys))
+	for i, key := range keys {
+		vk := d.getVKCode(key)
+		if vk == "" {
+			return fmt.Errorf("不支持的按键: %s", key)
+		}
+		vks[i] = vk
+	}
+	if _, ok := d.sender.(*PythonKeySender); !ok {
+		return pressChordSequential(d, keys, duration)
+	}
+
+	var py strings.Builder
+	py.WriteString("import ctypes,time;k=ctypes.windll.user32.keybd_event")
+	for _, vk := range vks {
+		fmt.Fprintf(&py, ";k(%s,0,0,0)", vk)
+	}
+	fmt.Fprintf(&py, ";time.sleep(%g)", duration.Seconds())
+	for i := len(vks) - 1; i >= 0; i-- {
+		fmt.Fprintf(&py, ";k(%s,0,2,0)", vks[i])
+	}
+	return exec.Command("python", "-c", py.String()).Run()
+}
+
 // pressWithDuration 按下-等待-释放
 func (d *WindowsDriver) pressWithDuration(key string, duration time.Duration) error {
 	if err := d.keyDown(key); err != nil {
@@ -221,7 +249,7 @@ func (d *WindowsDriver) Type(text string) error {
 
 // IsKeySupported 检查是否支持指定按键
 func (d *WindowsDriver) IsKeySupported(key string) bool {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	_, ok := d.winKeyMap[key]
 	return ok
 }
@@ -239,7 +267,7 @@ func (d *WindowsDriver) GetDriverType() string {
 
 // translateKey 通用按键名转 Windows VK
 func (d *WindowsDriver) translateKey(key string) string {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if winKey, ok := d.winKeyMap[key]; ok {
 		return winKey
 	}
@@ -247,7 +275,7 @@ func (d *WindowsDriver) translateKey(key string) string {
 }
 
 func (d *WindowsDriver) getVKCode(key string) string {
-	key = strings.ToLower(key)
+	key = CanonicalKey(key)
 	if vk, ok := d.winKeyMap[key]; ok {
 		return vk
 	}
@@ -0,0 +1,499 @@
+// Package conformance 是 act.KeyboardDriver 的一致性测试套件
+//
+// 各驱动对按键名和按键行为的理解并不一致（例如 macOS 驱动忽略字母键的持续时间）。
+// 任何驱动（包括第三方驱动）都可以在自己的 _test.go 中运行本套件，证明其行为符合约定：
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Target{
+//			New: func(t *testing.T) (act.KeyboardDriver, conformance.Sink) {
+//				return mydriver.New(), nil
+//			},
+//		})
+//	}
+//
+// 套件检查按键支持的一致性、KeyDown/KeyUp 配对、Close 时释放按键、并发安全、
+// Press 的持续时间、Type 的保真度，以及（驱动实现 act.ChordPresser 时）组合键的原子性。驱动能提供 Sink（观察主机实际收到的按键）时，
+// 按键状态和文本都按主机视角断言；否则只检查返回值和耗时。
+// 并发检查最好配合 go test -race 运行。
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pi-keyboard/act"
+	"pi-keyboard/decoder"
+	"pi-keyboard/hid"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RequiredKeys 所有驱动都必须支持的按键（使用 act 包的标准按键名）
+var RequiredKeys = []string{
+	"a", "m", "z", "0", "5", "9",
+	"enter", "esc", "backspace", "tab", "space",
+	"-", "=", ",", ".", "/",
+	"shift", "control", "alt",
+	"up", "down", "left", "right",
+	"home", "end", "pageup", "pagedown", "delete",
+	"f1", "f12",
+}
+
+// UnsupportedKeys 任何驱动都不应接受的按键名
+var UnsupportedKeys = []string{"", "no_such_key", "f99"}
+
+// DefaultText Type 检查默认输入的文本，包含大小写、需要 shift 的符号和换行
+const DefaultText = "Hello, World! 123 (x+y=z)\n"
+
+// 按键时间参数
+const (
+	pressDuration   = 10 * time.Millisecond
+	holdDuration    = 300 * time.Millisecond // 持续时间检查中按住的时长
+	holdProbe       = 100 * time.Millisecond // 按住期间观察主机状态的时间点
+	durationSlack   = 5 * time.Millisecond   // 计时误差
+	concurrentUsers = 8
+	concurrentRuns  = 50
+)
+
+// State 主机视角的键盘状态
+type State struct {
+	Held []string // 当前按住的按键，按名称排序
+	Text string   // 到目前为止主机看到的文本
+}
+
+// Sink 观察驱动实际发给主机的按键，如报文文件或虚拟驱动的事件记录
+type Sink interface {
+	State() (State, error)
+}
+
+// Target 待检查的驱动
+type Target struct {
+	// New 为每个检查项创建一个新的驱动和对应的 Sink，Sink 为 nil 时跳过主机视角的断言
+	// 驱动在检查项结束时关闭（检查项已关闭的除外）
+	New func(t *testing.T) (act.KeyboardDriver, Sink)
+
+	// Keys 驱动必须支持的按键，为空时使用 RequiredKeys
+	Keys []string
+
+	// Text Type 检查输入的文本，须能用驱动的主机布局输入，为空时使用 DefaultText
+	Text string
+
+	// Layout 驱动的主机布局名称，用于推算组合键在主机上产生的字符，为空时使用默认布局
+	Layout string
+}
+
+// Run 对目标驱动运行全部检查，每项检查是一个子测试
+func Run(t *testing.T, target Target) {
+	if target.New == nil {
+		t.Fatal("conformance: Target.New 为空")
+	}
+	if len(target.Keys) == 0 {
+		target.Keys = RequiredKeys
+	}
+	if target.Text == "" {
+		target.Text = DefaultText
+	}
+
+	checks := []struct {
+		name string
+		run  func(t *testing.T, h *harness)
+	}{
+		{"KeySupport", checkKeySupport},
+		{"KeyDownUpPairing", checkPairing},
+		{"ReleaseOnClose", checkReleaseOnClose},
+		{"PressDuration", checkPressDuration},
+		{"Concurrent", checkConcurrent},
+		{"TypeFidelity", checkType},
+		{"Chord", checkChord},
+	}
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			driver, sink := target.New(t)
+			if driver == nil {
+				t.Fatal("Target.New 返回了 nil 驱动")
+			}
+			h := &harness{t: t, target: target, layout: mustLayout(t, target.Layout), driver: driver, sink: sink}
+			t.Cleanup(h.close)
+			check.run(t, h)
+		})
+	}
+}
+
+// harness 一项检查使用的驱动和观察端
+type harness struct {
+	t      *testing.T
+	target Target
+	layout *act.Layout
+	driver act.KeyboardDriver
+	sink   Sink
+	closed bool
+}
+
+// close 关闭驱动（检查项未关闭时）
+func (h *harness) close() {
+	if h.closed {
+		return
+	}
+	h.closed = true
+	if err := h.driver.Close(); err != nil {
+		h.t.Errorf("Close: %v", err)
+	}
+}
+
+// state 读取主机视角的状态，没有 Sink 时返回 false
+func (h *harness) state() (State, bool) {
+	h.t.Helper()
+	if h.sink == nil {
+		return State{}, false
+	}
+	state, err := h.sink.State()
+	if err != nil {
+		h.t.Fatalf("读取 Sink 状态失败: %v", err)
+	}
+	return state, true
+}
+
+// expectHeld 断言主机看到按住的按键（顺序无关）
+func (h *harness) expectHeld(step string, want ...string) {
+	h.t.Helper()
+	state, ok := h.state()
+	if !ok {
+		return
+	}
+	want = sortedCopy(want)
+	if strings.Join(state.Held, " ") != strings.Join(want, " ") {
+		h.t.Errorf("%s: 主机看到按住的按键为 %q，期望 %q", step, state.Held, want)
+	}
+}
+
+// must 断言驱动调用成功
+func (h *harness) must(step string, err error) {
+	h.t.Helper()
+	if err != nil {
+		h.t.Fatalf("%s: %v", step, err)
+	}
+}
+
+// checkKeySupport 按键支持的一致性：必需按键都支持且大小写无关，别名与标准名等价，
+// 不支持的按键在所有方法上都被拒绝
+func checkKeySupport(t *testing.T, h *harness) {
+	d := h.driver
+	required := make(map[string]bool, len(h.target.Keys))
+	for _, key := range h.target.Keys {
+		required[key] = true
+		if !d.IsKeySupported(key) {
+			t.Errorf("IsKeySupported(%q) = false，该按键为必需按键", key)
+			continue
+		}
+		if upper := strings.ToUpper(key); upper != key && !d.IsKeySupported(upper) {
+			t.Errorf("IsKeySupported(%q) = false，按键名应大小写无关", upper)
+		}
+		if err := d.Press(key, pressDuration); err != nil {
+			t.Errorf("Press(%q): %v", key, err)
+		}
+	}
+	h.expectHeld("按下并释放所有必需按键后")
+
+	for alias, canonical := range act.KeyAliases() {
+		if required[canonical] && !d.IsKeySupported(alias) {
+			t.Errorf("IsKeySupported(%q) = false，别名应与标准名 %s 等价（见 act.CanonicalKey）", alias, canonical)
+		}
+	}
+
+	for _, key := range UnsupportedKeys {
+		if d.IsKeySupported(key) {
+			t.Errorf("IsKeySupported(%q) = true，期望不支持", key)
+			continue
+		}
+		if err := d.Press(key, pressDuration); err == nil {
+			t.Errorf("Press(%q) 未返回错误，IsKeySupported 为 false", key)
+		}
+		if err := d.KeyDown(key); err == nil {
+			t.Errorf("KeyDown(%q) 未返回错误，IsKeySupported 为 false", key)
+		}
+		if err := d.KeyUp(key); err == nil {
+			t.Errorf("KeyUp(%q) 未返回错误，IsKeySupported 为 false", key)
+		}
+	}
+	h.expectHeld("不支持的按键被拒绝后")
+}
+
+// checkPairing KeyDown/KeyUp 配对：只释放指定按键，重复按下不计数，释放未按下的按键无副作用，
+// 别名和标准名指同一个按键
+func checkPairing(t *testing.T, h *harness) {
+	d := h.driver
+	h.must("KeyDown(shift)", d.KeyDown("shift"))
+	h.must("KeyDown(a)", d.KeyDown("a"))
+	h.expectHeld("按下 shift 和 a 后", "shift", "a")
+
+	h.must("KeyUp(a)", d.KeyUp("a"))
+	h.expectHeld("释放 a 后", "shift")
+
+	h.must("KeyUp(shift)", d.KeyUp("shift"))
+	h.expectHeld("释放 shift 后")
+
+	h.must("KeyDown(b)", d.KeyDown("b"))
+	h.must("再次 KeyDown(b)", d.KeyDown("b"))
+	h.must("KeyUp(b)", d.KeyUp("b"))
+	h.expectHeld("两次按下、一次释放 b 后")
+
+	h.must("KeyDown(c)", d.KeyDown("c"))
+	h.must("KeyUp(d)", d.KeyUp("d"))
+	h.expectHeld("释放未按下的 d 后", "c")
+	h.must("KeyUp(c)", d.KeyUp("c"))
+	h.expectHeld("释放 c 后")
+
+	h.must("KeyDown(ctrl)", d.KeyDown("ctrl"))
+	h.expectHeld("用别名按下 ctrl 后", "control")
+	h.must("KeyUp(control)", d.KeyUp("control"))
+	h.expectHeld("用标准名释放 control 后")
+}
+
+// checkReleaseOnClose Close 释放所有仍按住的按键
+func checkReleaseOnClose(t *testing.T, h *harness) {
+	d := h.driver
+	h.must("KeyDown(shift)", d.KeyDown("shift"))
+	h.must("KeyDown(a)", d.KeyDown("a"))
+	h.expectHeld("Close 前", "shift", "a")
+
+	h.closed = true
+	if err := d.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	h.expectHeld("Close 后")
+}
+
+// checkPressDuration Press 在持续时间内保持按下，之后释放
+func checkPressDuration(t *testing.T, h *harness) {
+	d := h.driver
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- d.Press("a", holdDuration) }()
+
+	time.Sleep(holdProbe)
+	select {
+	case err := <-done:
+		t.Fatalf("Press(a, %v) 在 %v 后就返回了（err=%v），未保持持续时间", holdDuration, time.Since(start), err)
+	default:
+	}
+	h.expectHeld("Press 持续期间", "a")
+
+	h.must("Press(a)", <-done)
+	if elapsed := time.Since(start); elapsed < holdDuration-durationSlack {
+		t.Errorf("Press(a, %v) 耗时 %v，短于持续时间", holdDuration, elapsed)
+	}
+	h.expectHeld("Press 返回后")
+}
+
+// checkConcurrent 多个协程同时按键和查询，不报错且结束后没有残留按键
+func checkConcurrent(t *testing.T, h *harness) {
+	d := h.driver
+	keys := h.target.Keys
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentUsers*concurrentRuns)
+	for i := 0; i < concurrentUsers; i++ {
+		key := keys[i%len(keys)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < concurrentRuns; j++ {
+				if !d.IsKeySupported(key) {
+					errs <- fmt.Errorf("IsKeySupported(%q) = false", key)
+					return
+				}
+				if err := d.Press(key, 0); err != nil {
+					errs <- fmt.Errorf("Press(%q): %w", key, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("并发按键: %v", err)
+	}
+	h.expectHeld("并发按键结束后")
+}
+
+// checkType Type 输入的文本与主机看到的文本一致，结束后没有残留按键
+func checkType(t *testing.T, h *harness) {
+	h.must("Type", h.driver.Type(h.target.Text))
+	state, ok := h.state()
+	if !ok {
+		return
+	}
+	if state.Text != h.target.Text {
+		t.Errorf("Type(%q) 后主机看到 %q", h.target.Text, state.Text)
+	}
+	h.expectHeld("Type 结束后")
+}
+
+// checkChord 组合键在持续时间内同时按住，之后全部释放（驱动实现 act.ChordPresser 时）
+func checkChord(t *testing.T, h *harness) {
+	presser, ok := h.driver.(act.ChordPresser)
+	if !ok {
+		t.Skip("驱动未实现 act.ChordPresser")
+	}
+	done := make(chan error, 1)
+	go func() { done <- presser.PressChord([]string{"shift", "a"}, holdDuration) }()
+
+	time.Sleep(holdProbe)
+	h.expectHeld("组合键持续期间", "shift", "a")
+	h.must("PressChord(shift+a)", <-done)
+	h.expectHeld("组合键返回后")
+	if want, ok := layoutChar(h.layout, "a", "shift"); ok {
+		if state, ok := h.state(); ok && state.Text != string(want) {
+			t.Errorf("PressChord(shift+a) 后主机看到 %q，期望 %q（布局 %s）", state.Text, string(want), h.layout.Name)
+		}
+	}
+
+	if err := presser.PressChord([]string{"shift", "no_such_key"}, pressDuration); err == nil {
+		t.Error("PressChord 含不支持的按键时未返回错误")
+	}
+	h.expectHeld("不支持的组合键被拒绝后")
+}
+
+// layoutChar 在布局中查找按住 modifiers 按下 key 时主机输出的字符
+// 例如 shift+a 在 us 布局下为 A，在 fr 布局下为 Q
+func layoutChar(layout *act.Layout, key string, modifiers ...string) (rune, bool) {
+	want := sortedCopy(modifiers)
+	for char := rune(0x20); char < 0x250; char++ {
+		strokes, ok := layout.Strokes(char)
+		if !ok || len(strokes) != 1 || strokes[0].Key != key || strokes[0].Action != "" {
+			continue
+		}
+		if strings.Join(sortedCopy(strokes[0].Modifiers), " ") == strings.Join(want, " ") {
+			return char, true
+		}
+	}
+	return 0, false
+}
+
+// sortedCopy 返回排序后的副本
+func sortedCopy(keys []string) []string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// recorderSink 通过 act.EventRecorder 观察主机状态
+type recorderSink struct {
+	recorder act.EventRecorder
+}
+
+// RecorderSink 用记录事件的驱动（如虚拟驱动）作为 Sink
+func RecorderSink(recorder act.EventRecorder) Sink {
+	return recorderSink{recorder: recorder}
+}
+
+// State 返回记录器当前按住的按键和渲染的文本
+func (s recorderSink) State() (State, error) {
+	return State{Held: sortedCopy(s.recorder.PressedKeys()), Text: s.recorder.RenderedText()}, nil
+}
+
+// reportSink 解码报文文件观察主机状态
+type reportSink struct {
+	path     string
+	layout   *act.Layout
+	features hid.Features
+}
+
+// ReportSink 解码驱动以文件输出模式写入的键盘报文，按主机布局还原按住的按键和文本
+func ReportSink(path string, layout *act.Layout, features hid.Features) Sink {
+	return reportSink{path: path, layout: layout, features: features}
+}
+
+// State 从头解码报文文件，文件不存在时表示还没有写入任何报文
+func (s reportSink) State() (State, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	defer file.Close()
+
+	dec := decoder.New(s.layout, s.features)
+	held := make(map[string]bool)
+	err = dec.Stream(file, func(_ []byte, edges []decoder.Edge) {
+		for _, edge := range edges {
+			if edge.Down {
+				held[edge.Key] = true
+			} else {
+				delete(held, edge.Key)
+			}
+		}
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	state := State{Text: dec.Text()}
+	for key := range held {
+		state.Held = append(state.Held, key)
+	}
+	sort.Strings(state.Held)
+	return state, nil
+}
+
+// Virtual 虚拟驱动的检查目标，layout 为空时使用默认布局
+func Virtual(layout string) Target {
+	return Target{
+		New: func(t *testing.T) (act.KeyboardDriver, Sink) {
+			driver := act.NewVirtualDriver(mustLayout(t, layout))
+			return driver, RecorderSink(driver)
+		},
+		Layout: layout,
+	}
+}
+
+// LinuxOTG Linux OTG 驱动的检查目标：以文件输出模式写入临时文件，并解码报文观察主机状态
+// layout 为空时使用默认布局，features 为空时使用默认特性
+func LinuxOTG(layout string, features hid.Features) Target {
+	if features == (hid.Features{}) {
+		features = hid.DefaultFeatures()
+	}
+	return Target{
+		New: func(t *testing.T) (act.KeyboardDriver, Sink) {
+			dir := t.TempDir()
+			output := filepath.Join(dir, "hidg0")
+			options := []act.DriverOption{
+				act.WithDriverType(act.DriverTypeLinuxOTG),
+				act.WithOutputFile(output),
+				act.WithConsumerFile(filepath.Join(dir, "hidg1")),
+				act.WithFileSink(true),
+				act.WithHIDFeatures(features),
+				act.WithUDCRoot(filepath.Join(dir, "udc")), // 不存在的目录：主机状态保持 unknown
+			}
+			if layout != "" {
+				options = append(options, act.WithLayout(layout))
+			}
+			driver, err := act.NewDriverFactory().CreateDriver(options...)
+			if err != nil {
+				t.Fatalf("创建 Linux OTG 驱动失败: %v", err)
+			}
+			return driver, ReportSink(output, mustLayout(t, layout), features)
+		},
+		Layout: layout,
+	}
+}
+
+// mustLayout 按名称获取布局，名称为空时使用默认布局
+func mustLayout(t *testing.T, name string) *act.Layout {
+	t.Helper()
+	if name == "" {
+		name = act.DefaultLayoutName
+	}
+	layout, err := act.GetLayout(name)
+	if err != nil {
+		t.Fatalf("获取布局失败: %v", err)
+	}
+	return layout
+}
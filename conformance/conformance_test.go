@@ -0,0 +1,55 @@
+package conformance
+
+import (
+	"testing"
+
+	"pi-keyboard/hid"
+)
+
+func TestVirtual(t *testing.T) {
+	for _, layout := range []string{"", "de", "fr"} {
+		layout := layout
+		name := layout
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			Run(t, Virtual(layout))
+		})
+	}
+}
+
+func TestLinuxOTG(t *testing.T) {
+	targets := []struct {
+		name     string
+		layout   string
+		features hid.Features
+	}{
+		{"default", "", hid.DefaultFeatures()},
+		{"nkro", "", hid.Features{BootKeyboard: true, NKRO: true}},
+		{"fr-nkro", "fr", hid.Features{BootKeyboard: true, NKRO: true, LEDs: true}},
+	}
+	for _, tt := range targets {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			Run(t, LinuxOTG(tt.layout, tt.features))
+		})
+	}
+}
+
+func TestLayoutChar(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   rune
+	}{
+		{"us", 'A'},
+		{"de", 'A'},
+		{"fr", 'Q'},
+	}
+	for _, tt := range tests {
+		layout := mustLayout(t, tt.layout)
+		if got, ok := layoutChar(layout, "a", "shift"); !ok || got != tt.want {
+			t.Errorf("layoutChar(%s, shift+a) = %q, %v，期望 %q", tt.layout, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package decoder 把 HID 键盘报文流还原为按键边沿和主机会看到的文本
+//
+// 报文格式和布局表与驱动一致（hid 包的特性集合、act 包的布局），
+// 可以读回 -file-sink 模式下写入文件或 FIFO 的报文。
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"pi-keyboard/act"
+	"pi-keyboard/hid"
+	"sort"
+	"sync"
+)
+
+// Edge 按键边沿
+type Edge struct {
+	Key     string `json:"key"`
+	Keycode byte   `json:"keycode"`
+	Down    bool   `json:"down"`
+}
+
+// String 返回便于阅读的边沿描述，如 "+shift"、"-a"
+func (e Edge) String() string {
+	if e.Down {
+		return "+" + e.Key
+	}
+	return "-" + e.Key
+}
+
+// Decoder 键盘报文解码器，按报文差异生成按键边沿并渲染文本
+//
+// 方法可以并发调用（如 Stream 解码期间在其它 goroutine 读取 Text）。
+type Decoder struct {
+	mu             sync.Mutex
+	reportLength   int
+	protocol       byte                            // 主机当前使用的 HID 协议，决定 Stream 的报文长度
+	protocolSource func() (protocol byte, ok bool) // 为 nil 时使用 protocol
+	pressed        map[byte]bool                   // 当前按下的 keycode（含修饰键 0xe0-0xe7）
+	renderer       *act.TextRenderer
+	rollovers      int
+}
+
+// New 创建解码器，报文长度由特性集合决定（与驱动的编码器一致）
+func New(layout *act.Layout, features hid.Features) *Decoder {
+	return &Decoder{
+		reportLength: hid.NewKeyboardEncoder(features).ReportLength(),
+		protocol:     hid.ProtocolReport,
+		pressed:      make(map[byte]bool),
+		renderer:     act.NewTextRenderer(layout),
+	}
+}
+
+// ReportLength 报告协议下的报文长度
+func (d *Decoder) ReportLength() int {
+	return d.reportLength
+}
+
+// SetProtocol 设置主机当前使用的 HID 协议，boot 协议下 Stream 按 8 字节切分报文
+func (d *Decoder) SetProtocol(protocol byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.protocol = protocol
+}
+
+// SetProtocolSource 设置协议来源，Stream 在每份报文的第一个字节到达后调用它确定报文长度
+// ok 为 false 时沿用上一次的协议
+func (d *Decoder) SetProtocolSource(source func() (protocol byte, ok bool)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.protocolSource = source
+}
+
+// frameLength 返回下一份报文的长度
+func (d *Decoder) frameLength() int {
+	d.mu.Lock()
+	source := d.protocolSource
+	d.mu.Unlock()
+	if source != nil {
+		if protocol, ok := source(); ok {
+			d.SetProtocol(protocol)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.protocol == hid.ProtocolBoot {
+		return hid.BootReportLength
+	}
+	return d.reportLength
+}
+
+// Decode 解码一份报文，返回与上一份报文相比的按键边沿
+// 接受 8 字节 boot 报文和 NKRO 位图报文；ErrorRollOver 报文不改变按键状态
+func (d *Decoder) Decode(report []byte) ([]Edge, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var modifiers byte
+	var keys []byte
+	switch {
+	case len(report) == hid.BootReportLength:
+		modifiers = report[0]
+		if report[2] == hid.ErrorRollOver {
+			d.rollovers++
+			return nil, nil
+		}
+		for _, keycode := range report[2:] {
+			if keycode != 0 {
+				keys = append(keys, keycode)
+			}
+		}
+	case len(report) == d.reportLength && d.reportLength > hid.BootReportLength:
+		modifiers = report[0]
+		for i, bits := range report[1:] {
+			for bit := 0; bit < 8; bit++ {
+				if bits&(1<<bit) != 0 {
+					keys = append(keys, byte(i*8+bit))
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("报文长度 %d 无效（boot 为 %d，当前特性为 %d）",
+			len(report), hid.BootReportLength, d.reportLength)
+	}
+
+	next := make(map[byte]bool, len(keys)+8)
+	var pressedModifiers []byte
+	for i := 0; i < 8; i++ {
+		if modifiers&(1<<i) != 0 {
+			keycode := byte(hid.ModifierFirst + i)
+			next[keycode] = true
+			pressedModifiers = append(pressedModifiers, keycode)
+		}
+	}
+	for _, keycode := range keys {
+		next[keycode] = true
+	}
+
+	// 先释放，再按下修饰键，最后按下普通按键（按报文中的顺序）
+	var edges []Edge
+	var released []byte
+	for keycode := range d.pressed {
+		if !next[keycode] {
+			released = append(released, keycode)
+		}
+	}
+	sort.Slice(released, func(i, j int) bool { return released[i] < released[j] })
+	for _, keycode := range released {
+		edges = append(edges, Edge{Key: keyName(keycode), Keycode: keycode, Down: false})
+	}
+	for _, keycode := range pressedModifiers {
+		if !d.pressed[keycode] {
+			edges = append(edges, Edge{Key: keyName(keycode), Keycode: keycode, Down: true})
+		}
+	}
+
+	var held []string
+	for _, keycode := range pressedModifiers {
+		held = append(held, keyName(keycode))
+	}
+	for _, keycode := range keys {
+		if d.pressed[keycode] {
+			continue
+		}
+		name := keyName(keycode)
+		edges = append(edges, Edge{Key: name, Keycode: keycode, Down: true})
+		d.renderer.Press(name, held)
+	}
+
+	d.pressed = next
+	return edges, nil
+}
+
+// Text 返回到目前为止主机会看到的文本
+func (d *Decoder) Text() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.renderer.Text()
+}
+
+// Rollovers 返回收到的 ErrorRollOver 报文数（同时按下超过 6 个按键）
+func (d *Decoder) Rollovers() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rollovers
+}
+
+// Stream 读取报文流并逐份解码，读到 EOF 时返回 nil
+//
+// 报文长度按当前协议确定：报告协议为特性决定的长度，boot 协议为 8 字节。
+// NKRO 模式下主机切换协议后报文长度随之改变，须通过 SetProtocol 或 SetProtocolSource 告知解码器，
+// 报文本身无法区分两种格式。
+func (d *Decoder) Stream(r io.Reader, handle func(report []byte, edges []Edge)) error {
+	buf := make([]byte, d.reportLength)
+	for {
+		// 第一个字节到达后再确定长度，协议来源能反映写入这份报文时的协议
+		if _, err := io.ReadFull(r, buf[:1]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		report := buf[:d.frameLength()]
+		if _, err := io.ReadFull(r, report[1:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("报文流在报文中间结束，报文长度与特性或协议不一致？")
+			}
+			return err
+		}
+		edges, err := d.Decode(report)
+		if err != nil {
+			return err
+		}
+		handle(report, edges)
+	}
+}
+
+// keyName 返回 keycode 的按键名，未知 keycode 显示为十六进制
+func keyName(keycode byte) string {
+	if name, ok := act.KeyName(keycode); ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", keycode)
+}
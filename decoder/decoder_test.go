@@ -0,0 +1,248 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"pi-keyboard/act"
+	"pi-keyboard/hid"
+)
+
+func mustLayout(t *testing.T, name string) *act.Layout {
+	t.Helper()
+	layout, err := act.GetLayout(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layout
+}
+
+func TestDecodeBoot(t *testing.T) {
+	dec := New(mustLayout(t, "us"), hid.DefaultFeatures())
+	steps := []struct {
+		keycodes []byte
+		edges    string
+	}{
+		{[]byte{0xe1}, "+shift"},
+		{[]byte{0xe1, 0x04}, "+a"},
+		{[]byte{0xe1, 0x04, 0x05}, "+b"},
+		{[]byte{0x05}, "-a -shift"},
+		{nil, "-b"},
+	}
+	for _, step := range steps {
+		report := hid.EncodeBoot(step.keycodes)
+		edges, err := dec.Decode(report[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := edgeString(edges); got != step.edges {
+			t.Errorf("报文 % x: 边沿 %q，期望 %q", report, got, step.edges)
+		}
+	}
+	if dec.Text() != "AB" {
+		t.Errorf("Text() = %q，期望 \"AB\"", dec.Text())
+	}
+
+	// 超过 6 个按键的 ErrorRollOver 报文不改变按键状态
+	rollover := hid.EncodeBoot([]byte{4, 5, 6, 7, 8, 9, 10})
+	if edges, err := dec.Decode(rollover[:]); err != nil || len(edges) != 0 {
+		t.Errorf("ErrorRollOver 报文: 边沿 %v, %v", edges, err)
+	}
+	if dec.Rollovers() != 1 {
+		t.Errorf("Rollovers() = %d，期望 1", dec.Rollovers())
+	}
+
+	if _, err := dec.Decode(make([]byte, 5)); err == nil {
+		t.Error("长度无效的报文未返回错误")
+	}
+}
+
+func TestDecodeNKRO(t *testing.T) {
+	features := hid.Features{BootKeyboard: true, NKRO: true}
+	dec := New(mustLayout(t, "us"), features)
+	keycodes := []byte{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b}
+	edges, err := dec.Decode(hid.EncodeNKRO(keycodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := edgeString(edges); got != "+a +b +c +d +e +f +g +h" {
+		t.Errorf("NKRO 报文边沿 %q", got)
+	}
+
+	// NKRO 模式下仍接受切换到 boot 协议后的 8 字节报文
+	boot := hid.EncodeBoot([]byte{0x04})
+	edges, err = dec.Decode(boot[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := edgeString(edges); got != "-b -c -d -e -f -g -h" {
+		t.Errorf("boot 报文边沿 %q", got)
+	}
+}
+
+// TestFileSinkRoundTrip Linux OTG 驱动以文件输出模式写入的报文解码后与输入一致
+func TestFileSinkRoundTrip(t *testing.T) {
+	targets := []struct {
+		name     string
+		layout   string
+		features hid.Features
+		text     string
+	}{
+		{"us-boot", "us", hid.DefaultFeatures(), "Hello, World! 123 (x+y=z)\n"},
+		{"us-nkro", "us", hid.Features{BootKeyboard: true, NKRO: true, LEDs: true}, "~`!@#$%^&*()_+{}|:\"<>?\n"},
+		{"de-boot", "de", hid.Features{BootKeyboard: true}, "Größe 10€ @ zürich é\n"},
+		{"fr-nkro", "fr", hid.Features{BootKeyboard: true, NKRO: true}, "l'été à Paris: 5% ç\n"},
+	}
+	for _, tt := range targets {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := filepath.Join(dir, "hidg0")
+			driver, err := act.NewDriverFactory().CreateDriver(
+				act.WithDriverType(act.DriverTypeLinuxOTG),
+				act.WithOutputFile(output),
+				act.WithConsumerFile(filepath.Join(dir, "hidg1")),
+				act.WithFileSink(true),
+				act.WithHIDFeatures(tt.features),
+				act.WithLayout(tt.layout),
+				act.WithUDCRoot(filepath.Join(dir, "udc")),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := driver.Type(tt.text); err != nil {
+				t.Fatalf("Type: %v", err)
+			}
+			for _, key := range []string{"control", "alt", "delete"} {
+				if err := driver.KeyDown(key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := driver.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := New(mustLayout(t, tt.layout), tt.features)
+			if len(data)%dec.ReportLength() != 0 {
+				t.Fatalf("报文流长度 %d 不是报文长度 %d 的整数倍", len(data), dec.ReportLength())
+			}
+			var last []Edge
+			held := make(map[string]bool)
+			err = dec.Stream(bytes.NewReader(data), func(report []byte, edges []Edge) {
+				for _, edge := range edges {
+					held[edge.Key] = edge.Down
+				}
+				if len(edges) > 0 {
+					last = edges
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dec.Text() != tt.text {
+				t.Errorf("解码文本 %q，期望 %q", dec.Text(), tt.text)
+			}
+			// Close 释放按住的 ctrl+alt+delete（同一报文中的释放按 keycode 排序）
+			if got := edgeString(last); got != "-delete -control -alt" {
+				t.Errorf("Close 时的边沿 %q，期望 \"-delete -control -alt\"", got)
+			}
+			for key, down := range held {
+				if down {
+					t.Errorf("报文流结束时 %s 仍按下", key)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	dec := New(mustLayout(t, "us"), hid.DefaultFeatures())
+	report := hid.EncodeBoot([]byte{0x04})
+	data := append(report[:], 0, 0, 0)
+	var reports [][]byte
+	err := dec.Stream(bytes.NewReader(data), func(report []byte, _ []Edge) {
+		reports = append(reports, append([]byte{}, report...))
+	})
+	if err == nil {
+		t.Error("报文流在报文中间结束时未返回错误")
+	}
+	if !reflect.DeepEqual(reports, [][]byte{report[:]}) {
+		t.Errorf("已解码的报文 % x", reports)
+	}
+}
+
+// TestStreamProtocolSwitch NKRO 报文流中切换到 boot 协议后按 8 字节切分
+func TestStreamProtocolSwitch(t *testing.T) {
+	dec := New(mustLayout(t, "us"), hid.Features{BootKeyboard: true, NKRO: true})
+	boot := func(keycodes ...byte) []byte {
+		report := hid.EncodeBoot(keycodes)
+		return report[:]
+	}
+	frames := []struct {
+		protocol byte
+		report   []byte
+	}{
+		{hid.ProtocolReport, hid.EncodeNKRO([]byte{0xe1, 0x0b})},
+		{hid.ProtocolReport, hid.EncodeNKRO(nil)},
+		{hid.ProtocolBoot, boot(0x0c)},
+		{hid.ProtocolBoot, boot()},
+		{hid.ProtocolReport, hid.EncodeNKRO([]byte{0xe1, 0x1e})},
+		{hid.ProtocolReport, hid.EncodeNKRO(nil)},
+	}
+	var data []byte
+	for _, frame := range frames {
+		data = append(data, frame.report...)
+	}
+	next := 0
+	dec.SetProtocolSource(func() (byte, bool) {
+		if next >= len(frames) {
+			return 0, false
+		}
+		next++
+		return frames[next-1].protocol, true
+	})
+
+	// 解码期间在其它 goroutine 读取文本（host-sim 的 Ctrl+C）
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				dec.Text()
+			}
+		}
+	}()
+	var lengths []int
+	err := dec.Stream(bytes.NewReader(data), func(report []byte, _ []Edge) {
+		lengths = append(lengths, len(report))
+	})
+	close(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{22, 22, 8, 8, 22, 22}; !reflect.DeepEqual(lengths, want) {
+		t.Errorf("报文长度 %v，期望 %v", lengths, want)
+	}
+	if dec.Text() != "Hi!" {
+		t.Errorf("Text() = %q，期望 \"Hi!\"", dec.Text())
+	}
+}
+
+func edgeString(edges []Edge) string {
+	var b bytes.Buffer
+	for i, edge := range edges {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(edge.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package gadget
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"pi-keyboard/hid"
+)
+
+// 功能类型
+const (
+	FunctionKeyboard = hid.KindKeyboard // 键盘（/dev/hidg0）
+	FunctionConsumer = hid.KindConsumer // 媒体键 Consumer Control
+	FunctionMouse    = hid.KindMouse    // 鼠标（相对/绝对）
+)
+
+// 默认 function 名称
+//
+// 设备文件与名称无关：f_hid 按创建顺序分配 /dev/hidgN，只启用部分类型时编号前移
+// （如 boot,leds,mouse 时鼠标为 /dev/hidg1），服务按同样的顺序推算默认路径（act.DefaultHIDFile）。
+var defaultFunctionNames = map[string]string{
+	FunctionKeyboard: "hid.usb0",
+	FunctionConsumer: "hid.usb1",
+	FunctionMouse:    "hid.usb2",
+}
+
+// Config USB gadget 声明式配置
+type Config struct {
+	Name          string `json:"name"`       // configfs 下的 gadget 目录名
+	VendorID      uint16 `json:"vendor_id"`  // idVendor
+	ProductID     uint16 `json:"product_id"` // idProduct
+	BCDDevice     uint16 `json:"bcd_device"`
+	BCDUSB        uint16 `json:"bcd_usb"`
+	Serial        string `json:"serial"`
+	Manufacturer  string `json:"manufacturer"`
+	Product       string `json:"product"`
+	Configuration string `json:"configuration"` // 配置描述字符串
+	MaxPower      int    `json:"max_power"`     // mA
+	UDC           string `json:"udc,omitempty"` // 绑定的 UDC，为空时使用第一个可用 UDC
+
+	// Features HID 特性集合，决定各 function 的报告描述符，须与服务的 -hid-features 一致
+	Features  hid.Features `json:"features"`
+	Functions []Function   `json:"functions"` // 为空时按特性集合生成
+}
+
+// Function gadget 中的一个 HID function
+type Function struct {
+	Name string `json:"name"` // functions 下的目录名，如 hid.usb0
+	Kind string `json:"kind"` // keyboard、consumer、mouse
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	features := hid.DefaultFeatures()
+	return &Config{
+		Name:          "pi_keyboard",
+		VendorID:      0x1d6b, // Linux Foundation
+		ProductID:     0x0104, // Multifunction Composite Gadget
+		BCDDevice:     0x0100,
+		BCDUSB:        0x0200,
+		Serial:        defaultSerial(),
+		Manufacturer:  "Raspberry Pi",
+		Product:       "Pi Keyboard",
+		Configuration: "Config 1: HID Keyboard",
+		MaxPower:      120,
+		Features:      features,
+		Functions:     FunctionsFor(features),
+	}
+}
+
+// FunctionsFor 按特性集合生成 function 列表
+func FunctionsFor(features hid.Features) []Function {
+	var functions []Function
+	for _, kind := range features.Kinds() {
+		functions = append(functions, Function{Name: defaultFunctionNames[kind], Kind: kind})
+	}
+	return functions
+}
+
+// LoadConfig 从 JSON 文件加载配置，未填写的字段使用默认值
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 gadget 配置失败: %v", err)
+	}
+	config := DefaultConfig()
+	config.Functions = nil
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析 gadget 配置失败: %v", err)
+	}
+	if len(config.Functions) == 0 {
+		config.Functions = FunctionsFor(config.Features)
+	}
+	return config, config.Validate()
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("gadget 名称不能为空")
+	}
+	if err := c.Features.Validate(); err != nil {
+		return err
+	}
+	if len(c.Functions) == 0 {
+		return fmt.Errorf("至少需要一个 function")
+	}
+	seen := make(map[string]bool)
+	for _, fn := range c.Functions {
+		if fn.Name == "" {
+			return fmt.Errorf("function 名称不能为空")
+		}
+		if seen[fn.Name] {
+			return fmt.Errorf("function 名称重复: %s", fn.Name)
+		}
+		seen[fn.Name] = true
+		if _, err := c.Features.Function(fn.Kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultSerial 使用树莓派 CPU 序列号，读取失败时使用固定序列号
+func defaultSerial() string {
+	data, err := os.ReadFile("/sys/firmware/devicetree/base/serial-number")
+	if err != nil {
+		return "deadbeef1234"
+	}
+	serial := string(data)
+	for len(serial) > 0 && (serial[len(serial)-1] == 0 || serial[len(serial)-1] == '\n') {
+		serial = serial[:len(serial)-1]
+	}
+	if serial == "" {
+		return "deadbeef1234"
+	}
+	return serial
+}
@@ -0,0 +1,408 @@
+package gadget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pi-keyboard/hid"
+	"sort"
+	"strings"
+)
+
+// 默认路径
+const (
+	DefaultConfigfsRoot = "/sys/kernel/config/usb_gadget"
+	DefaultUDCRoot      = "/sys/class/udc"
+)
+
+// 配置和字符串目录（单配置、英语字符串）
+const (
+	configDir  = "configs/c.1"
+	stringsDir = "strings/0x409"
+)
+
+// Manager 通过 configfs 管理 USB gadget
+//
+// 所有路径都可配置，测试时可以指向临时目录。
+type Manager struct {
+	ConfigfsRoot string // usb_gadget 目录
+	UDCRoot      string // /sys/class/udc
+}
+
+// NewManager 创建使用默认路径的管理器
+func NewManager() *Manager {
+	return &Manager{
+		ConfigfsRoot: DefaultConfigfsRoot,
+		UDCRoot:      DefaultUDCRoot,
+	}
+}
+
+// Status gadget 状态
+type Status struct {
+	Name      string   `json:"name"`
+	Exists    bool     `json:"exists"`
+	UDC       string   `json:"udc,omitempty"`       // 已绑定的 UDC，为空表示未绑定
+	UDCState  string   `json:"udc_state,omitempty"` // 如 configured、suspended、not attached
+	Functions []string `json:"functions,omitempty"` // 已链接到配置的 function
+	UDCs      []string `json:"available_udcs"`
+}
+
+// Up 按配置创建 gadget 并绑定 UDC
+//
+// 可重复执行：已按相同配置绑定时不做任何修改；配置有变化时先解绑，更新后重新绑定。
+func (m *Manager) Up(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := m.ensureConfigfs(); err != nil {
+		return err
+	}
+
+	udc, err := m.resolveUDC(config.UDC)
+	if err != nil {
+		return err
+	}
+
+	dir := m.gadgetDir(config.Name)
+	bound := m.boundUDC(dir)
+
+	// 先检查是否需要修改
+	changed, err := m.apply(dir, config, true)
+	if err != nil {
+		return err
+	}
+	if !changed && bound == udc {
+		log.Printf("[GADGET] %s 已绑定到 %s，配置无变化", config.Name, udc)
+		return nil
+	}
+
+	// 已绑定的 gadget 不能修改属性，先解绑
+	if bound != "" {
+		if err := writeAttr(filepath.Join(dir, "UDC"), "\n"); err != nil {
+			return fmt.Errorf("解绑 UDC 失败: %v", err)
+		}
+		log.Printf("[GADGET] %s 已从 %s 解绑", config.Name, bound)
+	}
+
+	if _, err := m.apply(dir, config, false); err != nil {
+		return err
+	}
+
+	if err := writeAttr(filepath.Join(dir, "UDC"), udc); err != nil {
+		return fmt.Errorf("绑定 UDC %s 失败: %v", udc, err)
+	}
+	log.Printf("[GADGET] %s 已绑定到 %s", config.Name, udc)
+	return nil
+}
+
+// Down 解绑 UDC 并删除 gadget，gadget 不存在时直接返回
+func (m *Manager) Down(name string) error {
+	dir := m.gadgetDir(name)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[GADGET] %s 不存在，无需删除", name)
+		return nil
+	}
+
+	if m.boundUDC(dir) != "" {
+		if err := writeAttr(filepath.Join(dir, "UDC"), "\n"); err != nil {
+			return fmt.Errorf("解绑 UDC 失败: %v", err)
+		}
+	}
+
+	// 按 configfs 要求由内向外删除：链接 -> 配置 -> function -> 字符串 -> gadget
+	config := filepath.Join(dir, configDir)
+	links, _ := os.ReadDir(config)
+	for _, link := range links {
+		if link.Type()&os.ModeSymlink != 0 {
+			if err := os.Remove(filepath.Join(config, link.Name())); err != nil {
+				return fmt.Errorf("删除 function 链接失败: %v", err)
+			}
+		}
+	}
+	steps := []string{filepath.Join(config, stringsDir), config}
+	functions, _ := os.ReadDir(filepath.Join(dir, "functions"))
+	for _, fn := range functions {
+		steps = append(steps, filepath.Join(dir, "functions", fn.Name()))
+	}
+	steps = append(steps, filepath.Join(dir, stringsDir))
+	for _, path := range steps {
+		if err := removeDir(path); err != nil {
+			return err
+		}
+	}
+
+	// configfs 自动创建的子目录随 gadget 一起删除，普通目录（测试用）需要手动删除
+	for _, sub := range []string{"configs", "functions", "strings"} {
+		os.Remove(filepath.Join(dir, sub))
+	}
+	if err := removeDir(dir); err != nil {
+		return err
+	}
+	log.Printf("[GADGET] %s 已删除", name)
+	return nil
+}
+
+// Status 查询 gadget 状态
+func (m *Manager) Status(name string) (*Status, error) {
+	status := &Status{Name: name}
+	udcs, err := m.listUDCs()
+	if err != nil {
+		return nil, err
+	}
+	status.UDCs = udcs
+
+	dir := m.gadgetDir(name)
+	if _, err := os.Stat(dir); err != nil {
+		return status, nil
+	}
+	status.Exists = true
+	status.UDC = m.boundUDC(dir)
+	if status.UDC != "" {
+		status.UDCState = readAttr(filepath.Join(m.UDCRoot, status.UDC, "state"))
+	}
+
+	links, _ := os.ReadDir(filepath.Join(dir, configDir))
+	for _, link := range links {
+		if link.Type()&os.ModeSymlink != 0 {
+			status.Functions = append(status.Functions, link.Name())
+		}
+	}
+	return status, nil
+}
+
+// apply 创建目录、写入属性、链接 function；dryRun 为 true 时只检查是否需要修改
+func (m *Manager) apply(dir string, config *Config, dryRun bool) (bool, error) {
+	changed := false
+	mkdir := func(path string) error {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		changed = true
+		if dryRun {
+			return nil
+		}
+		return os.MkdirAll(path, 0755)
+	}
+	attr := func(path, value string) error {
+		if readAttr(path) == value {
+			return nil
+		}
+		changed = true
+		if dryRun {
+			return nil
+		}
+		return writeAttr(path, value)
+	}
+	binAttr := func(path string, value []byte) error {
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, value) {
+			return nil
+		}
+		changed = true
+		if dryRun {
+			return nil
+		}
+		return os.WriteFile(path, value, 0644)
+	}
+
+	steps := []func() error{
+		func() error { return mkdir(dir) },
+		func() error { return attr(filepath.Join(dir, "idVendor"), hex16(config.VendorID)) },
+		func() error { return attr(filepath.Join(dir, "idProduct"), hex16(config.ProductID)) },
+		func() error { return attr(filepath.Join(dir, "bcdDevice"), hex16(config.BCDDevice)) },
+		func() error { return attr(filepath.Join(dir, "bcdUSB"), hex16(config.BCDUSB)) },
+		func() error { return mkdir(filepath.Join(dir, stringsDir)) },
+		func() error { return attr(filepath.Join(dir, stringsDir, "serialnumber"), config.Serial) },
+		func() error { return attr(filepath.Join(dir, stringsDir, "manufacturer"), config.Manufacturer) },
+		func() error { return attr(filepath.Join(dir, stringsDir, "product"), config.Product) },
+		func() error { return mkdir(filepath.Join(dir, configDir, stringsDir)) },
+		func() error {
+			return attr(filepath.Join(dir, configDir, stringsDir, "configuration"), config.Configuration)
+		},
+		func() error { return attr(filepath.Join(dir, configDir, "MaxPower"), fmt.Sprint(config.MaxPower)) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return changed, fmt.Errorf("配置 gadget 失败: %v", err)
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, fn := range config.Functions {
+		wanted[fn.Name] = true
+		spec, _ := config.Features.Function(fn.Kind)
+		fnDir := filepath.Join(dir, "functions", fn.Name)
+		link := filepath.Join(dir, configDir, fn.Name)
+
+		// 已链接的 function 属性无法修改，属性不一致时先断开链接
+		if !dryRun && isSymlink(link) && functionDiffers(fnDir, spec) {
+			if err := os.Remove(link); err != nil {
+				return changed, fmt.Errorf("断开 function %s 失败: %v", fn.Name, err)
+			}
+		}
+
+		fnSteps := []func() error{
+			func() error { return mkdir(fnDir) },
+			func() error { return attr(filepath.Join(fnDir, "protocol"), fmt.Sprint(spec.Protocol)) },
+			func() error { return attr(filepath.Join(fnDir, "subclass"), fmt.Sprint(spec.Subclass)) },
+			func() error { return attr(filepath.Join(fnDir, "report_length"), fmt.Sprint(spec.ReportLength)) },
+			func() error { return binAttr(filepath.Join(fnDir, "report_desc"), spec.ReportDesc) },
+			func() error {
+				if isSymlink(link) {
+					return nil
+				}
+				changed = true
+				if dryRun {
+					return nil
+				}
+				return os.Symlink(fnDir, link)
+			},
+		}
+		for _, step := range fnSteps {
+			if err := step(); err != nil {
+				return changed, fmt.Errorf("配置 function %s 失败: %v", fn.Name, err)
+			}
+		}
+	}
+
+	// 移除配置中已不存在的 function 链接
+	links, _ := os.ReadDir(filepath.Join(dir, configDir))
+	for _, link := range links {
+		if link.Type()&os.ModeSymlink == 0 || wanted[link.Name()] {
+			continue
+		}
+		changed = true
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, configDir, link.Name())); err != nil {
+			return changed, fmt.Errorf("移除 function 链接 %s 失败: %v", link.Name(), err)
+		}
+	}
+	return changed, nil
+}
+
+// ensureConfigfs 确认 configfs 可用，使用默认路径时尝试加载 libcomposite 模块
+func (m *Manager) ensureConfigfs() error {
+	if _, err := os.Stat(m.ConfigfsRoot); err == nil {
+		return nil
+	}
+	if m.ConfigfsRoot == DefaultConfigfsRoot {
+		if out, err := exec.Command("modprobe", "libcomposite").CombinedOutput(); err != nil {
+			log.Printf("[GADGET] 加载 libcomposite 失败: %v %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	if _, err := os.Stat(m.ConfigfsRoot); err != nil {
+		return fmt.Errorf("configfs 不可用（需要 root 权限并加载 libcomposite）: %v", err)
+	}
+	return nil
+}
+
+// resolveUDC 返回要绑定的 UDC，未指定时使用第一个可用 UDC
+func (m *Manager) resolveUDC(udc string) (string, error) {
+	udcs, err := m.listUDCs()
+	if err != nil {
+		return "", err
+	}
+	if udc != "" {
+		for _, name := range udcs {
+			if name == udc {
+				return udc, nil
+			}
+		}
+		return "", fmt.Errorf("UDC %s 不存在，可用: %v", udc, udcs)
+	}
+	if len(udcs) == 0 {
+		return "", fmt.Errorf("没有可用的 UDC（%s 为空），请确认已启用 dwc2 overlay", m.UDCRoot)
+	}
+	return udcs[0], nil
+}
+
+// listUDCs 列出可用的 UDC
+func (m *Manager) listUDCs() ([]string, error) {
+	entries, err := os.ReadDir(m.UDCRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 UDC 列表失败: %v", err)
+	}
+	udcs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		udcs = append(udcs, entry.Name())
+	}
+	sort.Strings(udcs)
+	return udcs, nil
+}
+
+// gadgetDir 返回 gadget 目录
+func (m *Manager) gadgetDir(name string) string {
+	return filepath.Join(m.ConfigfsRoot, name)
+}
+
+// boundUDC 返回 gadget 已绑定的 UDC
+func (m *Manager) boundUDC(dir string) string {
+	return readAttr(filepath.Join(dir, "UDC"))
+}
+
+// functionDiffers 检查 function 属性是否与期望不一致
+func functionDiffers(fnDir string, spec hid.FunctionSpec) bool {
+	if readAttr(filepath.Join(fnDir, "protocol")) != fmt.Sprint(spec.Protocol) ||
+		readAttr(filepath.Join(fnDir, "subclass")) != fmt.Sprint(spec.Subclass) ||
+		readAttr(filepath.Join(fnDir, "report_length")) != fmt.Sprint(spec.ReportLength) {
+		return true
+	}
+	current, err := os.ReadFile(filepath.Join(fnDir, "report_desc"))
+	return err != nil || !bytes.Equal(current, spec.ReportDesc)
+}
+
+// readAttr 读取属性并去掉首尾空白，读取失败时返回空字符串
+func readAttr(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeAttr 写入属性
+func writeAttr(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
+// isSymlink 判断路径是否为符号链接
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// removeDir 删除目录
+// configfs 中 rmdir 会连同属性文件和内核创建的空目录一起删除；
+// 普通文件系统（测试用临时目录）中先删除其中的属性文件和空目录
+func removeDir(path string) error {
+	err := os.Remove(path)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	entries, readErr := os.ReadDir(path)
+	if readErr != nil {
+		return fmt.Errorf("删除 %s 失败: %v", path, err)
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			os.Remove(filepath.Join(path, entry.Name()))
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// hex16 格式化 16 位属性值
+func hex16(v uint16) string {
+	return fmt.Sprintf("0x%04x", v)
+}
@@ -0,0 +1,288 @@
+package gadget
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"pi-keyboard/hid"
+)
+
+const testUDC = "fe980000.usb"
+
+// newTestManager 在临时目录中模拟 configfs 和 UDC sysfs
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	root := t.TempDir()
+	m := &Manager{
+		ConfigfsRoot: filepath.Join(root, "usb_gadget"),
+		UDCRoot:      filepath.Join(root, "udc"),
+	}
+	if err := os.MkdirAll(m.ConfigfsRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(m.UDCRoot, testUDC), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(m.UDCRoot, testUDC, "state"), "configured\n")
+	return m
+}
+
+func testConfig(features hid.Features) *Config {
+	config := DefaultConfig()
+	config.Serial = "test"
+	config.Features = features
+	config.Functions = FunctionsFor(features)
+	return config
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ageFiles 把 gadget 中所有普通文件的修改时间改为很久以前，用于检测之后的写入
+func ageFiles(t *testing.T, dir string) time.Time {
+	t.Helper()
+	old := time.Unix(1000000000, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return old
+}
+
+// modifiedFiles 返回修改时间晚于 since 的普通文件（相对 dir 的路径）
+func modifiedFiles(t *testing.T, dir string, since time.Time) []string {
+	t.Helper()
+	var modified []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		if info.ModTime().After(since) {
+			rel, _ := filepath.Rel(dir, path)
+			modified = append(modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modified
+}
+
+// relativeLink 把 function 链接替换为指向同一目录的相对链接；
+// Up 重新链接时会创建绝对链接，据此判断链接是否被重建
+func relativeLink(t *testing.T, dir, name string) string {
+	t.Helper()
+	link := filepath.Join(dir, configDir, name)
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join("..", "..", "functions", name)
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func readLink(t *testing.T, path string) string {
+	t.Helper()
+	target, err := os.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestUpCreatesGadget(t *testing.T) {
+	m := newTestManager(t)
+	config := testConfig(hid.DefaultFeatures())
+	if err := m.Up(config); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	dir := m.gadgetDir(config.Name)
+	attrs := map[string]string{
+		"UDC":                                     testUDC,
+		"idVendor":                                "0x1d6b",
+		"idProduct":                               "0x0104",
+		filepath.Join(stringsDir, "product"):      "Pi Keyboard",
+		filepath.Join(configDir, "MaxPower"):      "120",
+		"functions/hid.usb0/report_length":        "8",
+		"functions/hid.usb0/protocol":             "1",
+		"functions/hid.usb0/subclass":             "1",
+		filepath.Join(stringsDir, "serialnumber"): "test",
+	}
+	for name, want := range attrs {
+		if got := readAttr(filepath.Join(dir, name)); got != want {
+			t.Errorf("%s = %q，期望 %q", name, got, want)
+		}
+	}
+	spec, _ := config.Features.Function(hid.KindKeyboard)
+	if desc, _ := os.ReadFile(filepath.Join(dir, "functions/hid.usb0/report_desc")); !bytes.Equal(desc, spec.ReportDesc) {
+		t.Error("键盘 report_desc 与特性集合生成的描述符不一致")
+	}
+	for _, fn := range config.Functions {
+		if !isSymlink(filepath.Join(dir, configDir, fn.Name)) {
+			t.Errorf("function %s 未链接到配置", fn.Name)
+		}
+	}
+}
+
+func TestUpTwiceMakesNoChanges(t *testing.T) {
+	m := newTestManager(t)
+	config := testConfig(hid.DefaultFeatures())
+	if err := m.Up(config); err != nil {
+		t.Fatalf("第一次 Up: %v", err)
+	}
+
+	dir := m.gadgetDir(config.Name)
+	target := relativeLink(t, dir, "hid.usb0")
+	since := ageFiles(t, dir)
+	if err := m.Up(config); err != nil {
+		t.Fatalf("第二次 Up: %v", err)
+	}
+	if modified := modifiedFiles(t, dir, since); len(modified) > 0 {
+		t.Errorf("第二次 Up 修改了 %v", modified)
+	}
+	if got := readLink(t, filepath.Join(dir, configDir, "hid.usb0")); got != target {
+		t.Errorf("第二次 Up 重建了 function 链接: %s", got)
+	}
+}
+
+func TestUpFeatureChangeRelinksAndRebinds(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Up(testConfig(hid.DefaultFeatures())); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// 启用 NKRO、去掉鼠标：键盘描述符变化，鼠标链接移除
+	features := hid.Features{BootKeyboard: true, NKRO: true, LEDs: true, Consumer: true}
+	config := testConfig(features)
+	dir := m.gadgetDir(config.Name)
+	relativeLink(t, dir, "hid.usb0")
+	consumerTarget := relativeLink(t, dir, "hid.usb1")
+	since := ageFiles(t, dir)
+
+	if err := m.Up(config); err != nil {
+		t.Fatalf("特性变化后 Up: %v", err)
+	}
+
+	modified := modifiedFiles(t, dir, since)
+	for _, want := range []string{"UDC", "functions/hid.usb0/report_desc", "functions/hid.usb0/report_length"} {
+		if !contains(modified, want) {
+			t.Errorf("特性变化后未更新 %s（修改的文件: %v）", want, modified)
+		}
+	}
+	if got := readAttr(filepath.Join(dir, "UDC")); got != testUDC {
+		t.Errorf("UDC = %q，期望重新绑定到 %s", got, testUDC)
+	}
+
+	spec, _ := features.Function(hid.KindKeyboard)
+	if desc, _ := os.ReadFile(filepath.Join(dir, "functions/hid.usb0/report_desc")); !bytes.Equal(desc, spec.ReportDesc) {
+		t.Error("键盘 report_desc 未更新为 NKRO 描述符")
+	}
+	keyboardLink := filepath.Join(dir, configDir, "hid.usb0")
+	if got := readLink(t, keyboardLink); !filepath.IsAbs(got) {
+		t.Errorf("键盘 function 属性变化后未重新链接: %s", got)
+	}
+	if got := readLink(t, filepath.Join(dir, configDir, "hid.usb1")); got != consumerTarget {
+		t.Errorf("属性未变化的媒体键 function 被重新链接: %s", got)
+	}
+	if isSymlink(filepath.Join(dir, configDir, "hid.usb2")) {
+		t.Error("鼠标 function 链接未移除")
+	}
+}
+
+func TestUpUnknownUDC(t *testing.T) {
+	m := newTestManager(t)
+	config := testConfig(hid.DefaultFeatures())
+	config.UDC = "missing.usb"
+	if err := m.Up(config); err == nil {
+		t.Fatal("UDC 不存在时 Up 未返回错误")
+	}
+	if _, err := os.Stat(m.gadgetDir(config.Name)); !os.IsNotExist(err) {
+		t.Error("UDC 不存在时仍创建了 gadget")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	m := newTestManager(t)
+	config := testConfig(hid.DefaultFeatures())
+
+	status, err := m.Status(config.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Status{Name: config.Name, UDCs: []string{testUDC}}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("创建前 Status = %+v，期望 %+v", status, want)
+	}
+
+	if err := m.Up(config); err != nil {
+		t.Fatal(err)
+	}
+	status, err = m.Status(config.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &Status{
+		Name:      config.Name,
+		Exists:    true,
+		UDC:       testUDC,
+		UDCState:  "configured",
+		Functions: []string{"hid.usb0", "hid.usb1", "hid.usb2"},
+		UDCs:      []string{testUDC},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("Up 后 Status = %+v，期望 %+v", status, want)
+	}
+}
+
+func TestDown(t *testing.T) {
+	m := newTestManager(t)
+	config := testConfig(hid.DefaultFeatures())
+	if err := m.Up(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Down(config.Name); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if _, err := os.Lstat(m.gadgetDir(config.Name)); !os.IsNotExist(err) {
+		t.Errorf("Down 后 gadget 目录仍存在: %v", err)
+	}
+	status, err := m.Status(config.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Exists {
+		t.Error("Down 后 Status.Exists 仍为 true")
+	}
+
+	// 再次 Down 不报错
+	if err := m.Down(config.Name); err != nil {
+		t.Errorf("gadget 不存在时 Down: %v", err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"pi-keyboard/gadget"
+	"pi-keyboard/hid"
+)
+
+// runGadgetCommand 处理 gadget 子命令：pi-keyboard gadget up|down|status
+func runGadgetCommand(args []string) error {
+	flags := flag.NewFlagSet("gadget", flag.ExitOnError)
+	configFile := flags.String("config", "", "gadget 配置文件 (JSON)，默认使用内置配置")
+	configfsRoot := flags.String("configfs", gadget.DefaultConfigfsRoot, "configfs 中 usb_gadget 目录")
+	udcRoot := flags.String("udc-root", gadget.DefaultUDCRoot, "UDC 目录")
+	name := flags.String("name", "", "gadget 名称，默认使用配置中的名称")
+	features := flags.String("hid-features", "", "HID 特性 (boot, nkro, leds, consumer, mouse)，覆盖配置文件，须与服务的 -hid-features 一致")
+	flags.Usage = func() {
+		fmt.Println("用法:")
+		fmt.Printf("  %s gadget up|down|status [选项]\n", os.Args[0])
+		fmt.Println()
+		fmt.Println("  up      创建 gadget 并绑定 UDC（可重复执行）")
+		fmt.Println("  down    解绑 UDC 并删除 gadget")
+		fmt.Println("  status  显示 gadget 状态")
+		fmt.Println()
+		fmt.Println("选项:")
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+		return fmt.Errorf("缺少子命令")
+	}
+	command := args[0]
+	flags.Parse(args[1:])
+
+	config := gadget.DefaultConfig()
+	if *configFile != "" {
+		loaded, err := gadget.LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		config = loaded
+	}
+	if *name != "" {
+		config.Name = *name
+	}
+	if *features != "" {
+		parsed, err := hid.ParseFeatures(*features)
+		if err != nil {
+			return err
+		}
+		config.Features = parsed
+		config.Functions = gadget.FunctionsFor(parsed)
+	}
+
+	manager := &gadget.Manager{ConfigfsRoot: *configfsRoot, UDCRoot: *udcRoot}
+	switch command {
+	case "up":
+		return manager.Up(config)
+	case "down":
+		return manager.Down(config.Name)
+	case "status":
+		status, err := manager.Status(config.Name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	default:
+		flags.Usage()
+		return fmt.Errorf("未知子命令: %s", command)
+	}
+}
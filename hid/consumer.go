@@ -0,0 +1,30 @@
+package hid
+
+import "encoding/binary"
+
+// ConsumerReportLength Consumer Control 报文长度（一个 16 位 usage）
+const ConsumerReportLength = 2
+
+// consumerUsageMax 描述符声明的最大 Consumer usage
+const consumerUsageMax = 0x3ff
+
+// consumerFunction 返回 Consumer Control function 属性
+func consumerFunction() FunctionSpec {
+	b := newDescriptorBuilder()
+	b.usagePage(pageConsumer).usage(0x01).collection(collectionApplication).
+		logical(0, consumerUsageMax).usageRange(0, consumerUsageMax).
+		report(16, 1).input(flagData | flagArray | flagAbsolute).
+		end()
+	return FunctionSpec{
+		Kind:         KindConsumer,
+		ReportLength: b.maxInputLength(),
+		ReportDesc:   b.buf,
+	}
+}
+
+// EncodeConsumer 构造 Consumer Control 报文（16 位 usage，小端），usage 为 0 表示释放
+func EncodeConsumer(usage uint16) []byte {
+	report := make([]byte, ConsumerReportLength)
+	binary.LittleEndian.PutUint16(report, usage)
+	return report
+}
@@ -0,0 +1,159 @@
+// Package hid 根据特性集合生成 HID 报告描述符，并提供与描述符一致的报文编码器
+package hid
+
+// 短条目前缀（HID 1.11 6.2.2.2，低 2 位为数据长度，由编码时决定）
+const (
+	// Main
+	itemInput         = 0x80
+	itemOutput        = 0x90
+	itemCollection    = 0xa0
+	itemEndCollection = 0xc0
+	// Global
+	itemUsagePage   = 0x04
+	itemLogicalMin  = 0x14
+	itemLogicalMax  = 0x24
+	itemReportSize  = 0x74
+	itemReportID    = 0x84
+	itemReportCount = 0x94
+	// Local
+	itemUsage    = 0x08
+	itemUsageMin = 0x18
+	itemUsageMax = 0x28
+)
+
+// Input/Output 条目标志位
+const (
+	flagData     = 0x00
+	flagConstant = 0x01
+	flagArray    = 0x00
+	flagVariable = 0x02
+	flagAbsolute = 0x00
+	flagRelative = 0x04
+)
+
+// 集合类型
+const (
+	collectionPhysical    = 0x00
+	collectionApplication = 0x01
+)
+
+// Usage Page
+const (
+	pageGenericDesktop = 0x01
+	pageKeyboard       = 0x07
+	pageLED            = 0x08
+	pageButton         = 0x09
+	pageConsumer       = 0x0c
+)
+
+// descriptorBuilder 逐条生成报告描述符，同时按报告 ID 统计报文长度
+//
+// 报文长度由写入的 Input/Output 条目累加得出，编码器据此校验，描述符和编码器不会各说各话。
+type descriptorBuilder struct {
+	buf         []byte
+	reportSize  int
+	reportCount int
+	reportID    byte
+	inputBits   map[byte]int // 报告 ID -> Input 位数
+	outputBits  map[byte]int // 报告 ID -> Output 位数
+}
+
+func newDescriptorBuilder() *descriptorBuilder {
+	return &descriptorBuilder{
+		inputBits:  make(map[byte]int),
+		outputBits: make(map[byte]int),
+	}
+}
+
+// unsigned 写入无符号数据的短条目，使用能容纳数据的最短长度
+func (b *descriptorBuilder) unsigned(prefix byte, value uint32) *descriptorBuilder {
+	switch {
+	case value <= 0xff:
+		b.buf = append(b.buf, prefix|1, byte(value))
+	case value <= 0xffff:
+		b.buf = append(b.buf, prefix|2, byte(value), byte(value>>8))
+	default:
+		b.buf = append(b.buf, prefix|3, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+	}
+	return b
+}
+
+// signed 写入有符号数据的短条目（逻辑最小/最大值）
+func (b *descriptorBuilder) signed(prefix byte, value int32) *descriptorBuilder {
+	switch {
+	case value >= -0x80 && value <= 0x7f:
+		b.buf = append(b.buf, prefix|1, byte(value))
+	case value >= -0x8000 && value <= 0x7fff:
+		b.buf = append(b.buf, prefix|2, byte(value), byte(value>>8))
+	default:
+		b.buf = append(b.buf, prefix|3, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+	}
+	return b
+}
+
+func (b *descriptorBuilder) usagePage(page uint32) *descriptorBuilder {
+	return b.unsigned(itemUsagePage, page)
+}
+
+func (b *descriptorBuilder) usage(usage uint32) *descriptorBuilder {
+	return b.unsigned(itemUsage, usage)
+}
+
+func (b *descriptorBuilder) usageRange(min, max uint32) *descriptorBuilder {
+	return b.unsigned(itemUsageMin, min).unsigned(itemUsageMax, max)
+}
+
+func (b *descriptorBuilder) logical(min, max int32) *descriptorBuilder {
+	return b.signed(itemLogicalMin, min).signed(itemLogicalMax, max)
+}
+
+// report 设置后续字段的位宽和数量
+func (b *descriptorBuilder) report(size, count int) *descriptorBuilder {
+	b.reportSize, b.reportCount = size, count
+	b.unsigned(itemReportSize, uint32(size))
+	return b.unsigned(itemReportCount, uint32(count))
+}
+
+func (b *descriptorBuilder) id(reportID byte) *descriptorBuilder {
+	b.reportID = reportID
+	return b.unsigned(itemReportID, uint32(reportID))
+}
+
+func (b *descriptorBuilder) input(flags byte) *descriptorBuilder {
+	b.inputBits[b.reportID] += b.reportSize * b.reportCount
+	return b.unsigned(itemInput, uint32(flags))
+}
+
+func (b *descriptorBuilder) output(flags byte) *descriptorBuilder {
+	b.outputBits[b.reportID] += b.reportSize * b.reportCount
+	return b.unsigned(itemOutput, uint32(flags))
+}
+
+func (b *descriptorBuilder) collection(kind byte) *descriptorBuilder {
+	return b.unsigned(itemCollection, uint32(kind))
+}
+
+func (b *descriptorBuilder) end() *descriptorBuilder {
+	b.buf = append(b.buf, itemEndCollection)
+	return b
+}
+
+// inputLength 返回指定报告 ID 的 Input 报文字节数（有报告 ID 时包含 ID 字节）
+func (b *descriptorBuilder) inputLength(reportID byte) int {
+	length := (b.inputBits[reportID] + 7) / 8
+	if reportID != 0 {
+		length++
+	}
+	return length
+}
+
+// maxInputLength 返回最长的 Input 报文字节数，即 f_hid 的 report_length
+func (b *descriptorBuilder) maxInputLength() int {
+	max := 0
+	for id := range b.inputBits {
+		if length := b.inputLength(id); length > max {
+			max = length
+		}
+	}
+	return max
+}
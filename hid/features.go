@@ -0,0 +1,152 @@
+package hid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Function 类型
+const (
+	KindKeyboard = "keyboard" // 键盘（boot 6 键或 NKRO 位图）
+	KindConsumer = "consumer" // 媒体键 Consumer Control
+	KindMouse    = "mouse"    // 鼠标（相对/绝对）
+)
+
+// 特性名称（用于命令行和配置文件）
+const (
+	FeatureBootKeyboard = "boot"     // boot 协议键盘（BIOS/UEFI 可用）
+	FeatureNKRO         = "nkro"     // 键盘报文使用按键位图，不限同时按下的按键数
+	FeatureLEDs         = "leds"     // 键盘接收主机 LED 输出报文
+	FeatureConsumer     = "consumer" // 媒体键
+	FeatureMouse        = "mouse"    // 鼠标
+)
+
+// Features HID 特性集合
+//
+// 同一个特性集合既用于生成 gadget 的报告描述符，也用于选择驱动的报文编码器。
+type Features struct {
+	BootKeyboard bool `json:"boot_keyboard"`
+	NKRO         bool `json:"nkro"`
+	LEDs         bool `json:"leds"`
+	Consumer     bool `json:"consumer"`
+	Mouse        bool `json:"mouse"`
+}
+
+// DefaultFeatures 默认特性：boot 键盘、LED、媒体键、鼠标
+func DefaultFeatures() Features {
+	return Features{BootKeyboard: true, LEDs: true, Consumer: true, Mouse: true}
+}
+
+// ParseFeatures 解析逗号分隔的特性列表，如 "boot,leds,consumer,mouse"
+func ParseFeatures(s string) (Features, error) {
+	var f Features
+	for _, name := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case FeatureBootKeyboard:
+			f.BootKeyboard = true
+		case FeatureNKRO:
+			f.NKRO = true
+		case FeatureLEDs:
+			f.LEDs = true
+		case FeatureConsumer:
+			f.Consumer = true
+		case FeatureMouse:
+			f.Mouse = true
+		default:
+			return f, fmt.Errorf("未知的 HID 特性: %s (可选 %s)", name, strings.Join(FeatureNames(), ", "))
+		}
+	}
+	return f, f.Validate()
+}
+
+// FeatureNames 返回所有特性名称
+func FeatureNames() []string {
+	names := []string{FeatureBootKeyboard, FeatureNKRO, FeatureLEDs, FeatureConsumer, FeatureMouse}
+	sort.Strings(names)
+	return names
+}
+
+// String 返回逗号分隔的特性列表，与 ParseFeatures 互逆
+func (f Features) String() string {
+	var names []string
+	for _, feature := range []struct {
+		name    string
+		enabled bool
+	}{
+		{FeatureBootKeyboard, f.BootKeyboard},
+		{FeatureNKRO, f.NKRO},
+		{FeatureLEDs, f.LEDs},
+		{FeatureConsumer, f.Consumer},
+		{FeatureMouse, f.Mouse},
+	} {
+		if feature.enabled {
+			names = append(names, feature.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// HasKeyboard 是否包含键盘 function
+func (f Features) HasKeyboard() bool {
+	return f.BootKeyboard || f.NKRO
+}
+
+// Validate 校验特性组合
+func (f Features) Validate() error {
+	if f.LEDs && !f.HasKeyboard() {
+		return fmt.Errorf("HID 特性 %s 需要键盘（%s 或 %s）", FeatureLEDs, FeatureBootKeyboard, FeatureNKRO)
+	}
+	if !f.HasKeyboard() && !f.Consumer && !f.Mouse {
+		return fmt.Errorf("HID 特性集合为空")
+	}
+	return nil
+}
+
+// FunctionSpec HID function 的 configfs 属性
+type FunctionSpec struct {
+	Kind         string
+	Protocol     int
+	Subclass     int
+	ReportLength int
+	ReportDesc   []byte
+}
+
+// Function 返回指定类型的 function 属性，特性集合未启用该类型时返回错误
+func (f Features) Function(kind string) (FunctionSpec, error) {
+	switch kind {
+	case KindKeyboard:
+		if !f.HasKeyboard() {
+			return FunctionSpec{}, fmt.Errorf("HID 特性未启用键盘")
+		}
+		return keyboardFunction(f), nil
+	case KindConsumer:
+		if !f.Consumer {
+			return FunctionSpec{}, fmt.Errorf("HID 特性未启用 %s", FeatureConsumer)
+		}
+		return consumerFunction(), nil
+	case KindMouse:
+		if !f.Mouse {
+			return FunctionSpec{}, fmt.Errorf("HID 特性未启用 %s", FeatureMouse)
+		}
+		return mouseFunction(), nil
+	default:
+		return FunctionSpec{}, fmt.Errorf("未知的 function 类型: %s (可选 keyboard, consumer, mouse)", kind)
+	}
+}
+
+// Kinds 返回特性集合启用的 function 类型，顺序为 keyboard、consumer、mouse
+func (f Features) Kinds() []string {
+	var kinds []string
+	if f.HasKeyboard() {
+		kinds = append(kinds, KindKeyboard)
+	}
+	if f.Consumer {
+		kinds = append(kinds, KindConsumer)
+	}
+	if f.Mouse {
+		kinds = append(kinds, KindMouse)
+	}
+	return kinds
+}
@@ -0,0 +1,208 @@
+package hid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 键盘报文布局
+//
+// boot 报文（8 字节）：
+//
+//	byte 0   修饰键位图
+//	byte 1   保留
+//	byte 2-7 最多 6 个同时按下的普通按键
+//
+// NKRO 报文：
+//
+//	byte 0   修饰键位图
+//	byte 1-  普通按键位图，keycode n 对应 byte 1+n/8 的第 n%8 位
+const (
+	BootReportLength   = 8    // boot 报文长度
+	BootReportKeySlots = 6    // boot 报文可容纳的普通按键数
+	ErrorRollOver      = 0x01 // 按键数超出时填充的 ErrorRollOver 码
+
+	ModifierFirst = 0xe0 // 左 Control
+	ModifierLast  = 0xe7 // 右 GUI
+
+	// KeyUsageMax 描述符声明的最大普通按键 usage（覆盖到 LANG 键和国际键）
+	KeyUsageMax = 0xa4
+
+	nkroBitmapBytes = (KeyUsageMax + 1 + 7) / 8
+)
+
+// IsModifier 判断 keycode 是否为修饰键（0xe0-0xe7）
+func IsModifier(keycode byte) bool {
+	return keycode >= ModifierFirst && keycode <= ModifierLast
+}
+
+// ModifierBit 返回修饰键在 byte 0 中对应的位
+func ModifierBit(keycode byte) byte {
+	return 1 << (keycode - ModifierFirst)
+}
+
+// SplitModifiers 将 keycode 列表拆分为修饰键位图和普通按键
+func SplitModifiers(keycodes []byte) (byte, []byte) {
+	var modifiers byte
+	keys := make([]byte, 0, len(keycodes))
+	for _, keycode := range keycodes {
+		if IsModifier(keycode) {
+			modifiers |= ModifierBit(keycode)
+			continue
+		}
+		keys = append(keys, keycode)
+	}
+	return modifiers, keys
+}
+
+// keyboardDescriptor 生成键盘报告描述符
+func keyboardDescriptor(f Features) *descriptorBuilder {
+	b := newDescriptorBuilder()
+	b.usagePage(pageGenericDesktop).usage(0x06).collection(collectionApplication)
+
+	// 修饰键位图
+	b.usagePage(pageKeyboard).usageRange(ModifierFirst, ModifierLast).logical(0, 1).
+		report(1, 8).input(flagData | flagVariable | flagAbsolute)
+
+	if !f.NKRO {
+		// 保留字节
+		b.report(8, 1).input(flagConstant)
+	}
+
+	if f.LEDs {
+		// LED 输出报文：Num、Caps、Scroll、Compose、Kana + 3 位填充
+		b.usagePage(pageLED).usageRange(1, 5).report(1, 5).output(flagData | flagVariable | flagAbsolute)
+		b.report(3, 1).output(flagConstant)
+	}
+
+	if f.NKRO {
+		// 普通按键位图
+		b.usagePage(pageKeyboard).usageRange(0, nkroBitmapBytes*8-1).logical(0, 1).
+			report(1, nkroBitmapBytes*8).input(flagData | flagVariable | flagAbsolute)
+	} else {
+		// 6 个按键槽位
+		b.usagePage(pageKeyboard).logical(0, KeyUsageMax).usageRange(0, KeyUsageMax).
+			report(8, BootReportKeySlots).input(flagData | flagArray | flagAbsolute)
+	}
+
+	b.end()
+	return b
+}
+
+// keyboardFunction 返回键盘 function 属性
+// 声明 boot 子类的键盘在 BIOS/UEFI 中也可使用
+func keyboardFunction(f Features) FunctionSpec {
+	b := keyboardDescriptor(f)
+	spec := FunctionSpec{
+		Kind:         KindKeyboard,
+		ReportLength: b.maxInputLength(),
+		ReportDesc:   b.buf,
+	}
+	if f.BootKeyboard {
+		spec.Protocol, spec.Subclass = 1, 1
+	}
+	return spec
+}
+
+// KeyboardEncoder 键盘报文编码器，报文格式由特性集合决定
+type KeyboardEncoder struct {
+	nkro         bool
+	reportLength int
+}
+
+// NewKeyboardEncoder 按特性集合创建键盘报文编码器
+func NewKeyboardEncoder(f Features) *KeyboardEncoder {
+	return &KeyboardEncoder{
+		nkro:         f.NKRO,
+		reportLength: keyboardDescriptor(f).maxInputLength(),
+	}
+}
+
+// NKRO 是否使用位图报文
+func (e *KeyboardEncoder) NKRO() bool {
+	return e.nkro
+}
+
+// ReportLength 报文长度
+func (e *KeyboardEncoder) ReportLength() int {
+	return e.reportLength
+}
+
+// Encode 根据按下的 keycode 列表构造报文
+func (e *KeyboardEncoder) Encode(keycodes []byte) []byte {
+	var report []byte
+	if e.nkro {
+		report = EncodeNKRO(keycodes)
+	} else {
+		boot := EncodeBoot(keycodes)
+		report = boot[:]
+	}
+	return report[:e.reportLength]
+}
+
+// EncodeBoot 构造 8 字节 boot 报文
+// 修饰键写入 byte 0 的位图，不占用按键槽位；
+// 普通按键超过 6 个时按 HID 规范所有按键槽位填充 ErrorRollOver
+func EncodeBoot(keycodes []byte) [BootReportLength]byte {
+	var report [BootReportLength]byte
+	modifiers, keycodes := SplitModifiers(keycodes)
+	report[0] = modifiers
+	if len(keycodes) > BootReportKeySlots {
+		for i := 0; i < BootReportKeySlots; i++ {
+			report[2+i] = ErrorRollOver
+		}
+		return report
+	}
+	copy(report[2:], keycodes)
+	return report
+}
+
+// EncodeNKRO 构造 NKRO 位图报文，超出 KeyUsageMax 的 keycode 被忽略
+func EncodeNKRO(keycodes []byte) []byte {
+	report := make([]byte, 1+nkroBitmapBytes)
+	modifiers, keycodes := SplitModifiers(keycodes)
+	report[0] = modifiers
+	for _, keycode := range keycodes {
+		if keycode > KeyUsageMax {
+			continue
+		}
+		report[1+keycode/8] |= 1 << (keycode % 8)
+	}
+	return report
+}
+
+// HID 协议（SET_PROTOCOL 请求的 wValue）
+const (
+	ProtocolBoot   = 0 // boot 协议：主机只解析 8 字节 boot 报文（BIOS/UEFI）
+	ProtocolReport = 1 // 报告协议：主机按报告描述符解析报文（USB 复位后的默认协议）
+)
+
+// ParseProtocol 解析协议名称，接受 boot/report 或 0/1
+func ParseProtocol(s string) (byte, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "0", "boot":
+		return ProtocolBoot, nil
+	case "1", "report":
+		return ProtocolReport, nil
+	default:
+		return 0, fmt.Errorf("未知的 HID 协议: %q (可选 boot, report)", s)
+	}
+}
+
+// ProtocolName 返回协议名称
+func ProtocolName(protocol byte) string {
+	if protocol == ProtocolBoot {
+		return "boot"
+	}
+	return "report"
+}
+
+// EncodeProtocol 按主机选择的协议构造报文
+// boot 协议下总是发送 8 字节 boot 报文，NKRO 模式借此在 BIOS 中继续可用
+func (e *KeyboardEncoder) EncodeProtocol(keycodes []byte, protocol byte) []byte {
+	if protocol == ProtocolBoot {
+		report := EncodeBoot(keycodes)
+		return report[:]
+	}
+	return e.Encode(keycodes)
+}
@@ -0,0 +1,144 @@
+package hid
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 常用 keycode
+const (
+	keyA      = 0x04
+	keyB      = 0x05
+	keyC      = 0x06
+	keyD      = 0x07
+	keyE      = 0x08
+	keyF      = 0x09
+	keyG      = 0x0a
+	keyEnter  = 0x28
+	keyLCtrl  = 0xe0
+	keyLShift = 0xe1
+	keyRAlt   = 0xe6
+	keyRGUI   = 0xe7
+)
+
+func TestEncodeBoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		keycodes []byte
+		want     []byte
+	}{
+		{"空", nil, []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{"单键", []byte{keyA}, []byte{0, 0, keyA, 0, 0, 0, 0, 0}},
+		{"修饰键不占槽位", []byte{keyLShift, keyA}, []byte{0x02, 0, keyA, 0, 0, 0, 0, 0}},
+		{"多个修饰键", []byte{keyLCtrl, keyRAlt, keyRGUI}, []byte{0x01 | 0x40 | 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"保持按下顺序", []byte{keyC, keyA, keyB}, []byte{0, 0, keyC, keyA, keyB, 0, 0, 0}},
+		{"6 键", []byte{keyA, keyB, keyC, keyD, keyE, keyF}, []byte{0, 0, keyA, keyB, keyC, keyD, keyE, keyF}},
+		{"6 键加修饰键", []byte{keyLShift, keyA, keyB, keyC, keyD, keyE, keyF}, []byte{0x02, 0, keyA, keyB, keyC, keyD, keyE, keyF}},
+		{"超过 6 键", []byte{keyLCtrl, keyA, keyB, keyC, keyD, keyE, keyF, keyG}, []byte{0x01, 0, 1, 1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		got := EncodeBoot(tt.keycodes)
+		if !bytes.Equal(got[:], tt.want) {
+			t.Errorf("%s: EncodeBoot(% x) = % x，期望 % x", tt.name, tt.keycodes, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeNKRO(t *testing.T) {
+	tests := []struct {
+		name     string
+		keycodes []byte
+		set      map[int]byte // 报文下标 -> 期望值，其余字节为 0
+	}{
+		{"空", nil, nil},
+		{"单键", []byte{keyA}, map[int]byte{1: 1 << 4}},
+		{"修饰键", []byte{keyLShift, keyEnter}, map[int]byte{0: 0x02, 1 + keyEnter/8: 1 << (keyEnter % 8)}},
+		{"同一字节的多个按键", []byte{keyA, keyB, keyC, keyD}, map[int]byte{1: 0xf0}},
+		{"超过 6 键不溢出", []byte{keyA, keyB, keyC, keyD, keyE, keyF, keyG}, map[int]byte{1: 0xf0, 2: 0x07}},
+		{"最大 usage", []byte{KeyUsageMax}, map[int]byte{1 + KeyUsageMax/8: 1 << (KeyUsageMax % 8)}},
+		{"超出范围的 keycode 被忽略", []byte{KeyUsageMax + 1, 0xdf}, nil},
+	}
+	for _, tt := range tests {
+		got := EncodeNKRO(tt.keycodes)
+		if len(got) != 1+nkroBitmapBytes {
+			t.Fatalf("%s: 报文长度 %d，期望 %d", tt.name, len(got), 1+nkroBitmapBytes)
+		}
+		want := make([]byte, len(got))
+		for i, v := range tt.set {
+			want[i] = v
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: EncodeNKRO(% x) = % x，期望 % x", tt.name, tt.keycodes, got, want)
+		}
+	}
+}
+
+func TestKeyboardEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		features Features
+		nkro     bool
+		length   int
+	}{
+		{"boot", Features{BootKeyboard: true}, false, BootReportLength},
+		{"boot+leds", DefaultFeatures(), false, BootReportLength},
+		{"nkro", Features{BootKeyboard: true, NKRO: true}, true, 1 + nkroBitmapBytes},
+		{"nkro+leds", Features{BootKeyboard: true, NKRO: true, LEDs: true}, true, 1 + nkroBitmapBytes},
+	}
+	keycodes := []byte{keyLShift, keyA, keyB}
+	for _, tt := range tests {
+		e := NewKeyboardEncoder(tt.features)
+		if e.NKRO() != tt.nkro || e.ReportLength() != tt.length {
+			t.Errorf("%s: NKRO=%v ReportLength=%d，期望 %v %d", tt.name, e.NKRO(), e.ReportLength(), tt.nkro, tt.length)
+			continue
+		}
+		// 报文长度须与 gadget 的 report_length 一致
+		if spec, _ := tt.features.Function(KindKeyboard); spec.ReportLength != tt.length {
+			t.Errorf("%s: function report_length %d 与编码器报文长度 %d 不一致", tt.name, spec.ReportLength, tt.length)
+		}
+
+		report := e.Encode(keycodes)
+		if len(report) != tt.length {
+			t.Errorf("%s: Encode 报文长度 %d", tt.name, len(report))
+		}
+		if report[0] != 0x02 {
+			t.Errorf("%s: 修饰键字节 %#x，期望 0x02", tt.name, report[0])
+		}
+
+		// boot 协议下总是 8 字节 boot 报文
+		boot := EncodeBoot(keycodes)
+		if got := e.EncodeProtocol(keycodes, ProtocolBoot); !bytes.Equal(got, boot[:]) {
+			t.Errorf("%s: boot 协议报文 % x，期望 % x", tt.name, got, boot)
+		}
+		if got := e.EncodeProtocol(keycodes, ProtocolReport); !bytes.Equal(got, report) {
+			t.Errorf("%s: 报告协议报文 % x，期望 % x", tt.name, got, report)
+		}
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	for input, want := range map[string]byte{"0": ProtocolBoot, "boot": ProtocolBoot, " BOOT\n": ProtocolBoot, "1": ProtocolReport, "report": ProtocolReport} {
+		if got, err := ParseProtocol(input); err != nil || got != want {
+			t.Errorf("ParseProtocol(%q) = %d, %v，期望 %d", input, got, err, want)
+		}
+	}
+	if _, err := ParseProtocol("2"); err == nil {
+		t.Error("ParseProtocol(\"2\") 未返回错误")
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFeatures("boot, NKRO,leds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Features{BootKeyboard: true, NKRO: true, LEDs: true}); f != want {
+		t.Errorf("ParseFeatures = %+v，期望 %+v", f, want)
+	}
+	if f.String() != "boot,nkro,leds" {
+		t.Errorf("String() = %q", f.String())
+	}
+	if _, err := ParseFeatures("boot,joystick"); err == nil {
+		t.Error("未知特性未返回错误")
+	}
+}
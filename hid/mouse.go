@@ -0,0 +1,74 @@
+package hid
+
+import "encoding/binary"
+
+// 鼠标报文 ID
+const (
+	MouseReportRelative = 0x01 // [id, buttons, dx, dy, wheel, pan]
+	MouseReportAbsolute = 0x02 // [id, buttons, x(16), y(16), wheel, pan]
+)
+
+const (
+	MouseButtons = 5      // 左、右、中、后退、前进
+	MouseAbsMax  = 0x7fff // 绝对坐标的最大值
+)
+
+const usageACPan = 0x238 // Consumer 页水平滚动
+
+// mouseDescriptor 生成鼠标报告描述符：报告 ID 1 为相对移动，报告 ID 2 为绝对定位
+func mouseDescriptor() *descriptorBuilder {
+	b := newDescriptorBuilder()
+	for _, reportID := range []byte{MouseReportRelative, MouseReportAbsolute} {
+		b.usagePage(pageGenericDesktop).usage(0x02).collection(collectionApplication).id(reportID)
+		b.usage(0x01).collection(collectionPhysical)
+
+		// 按钮位图 + 填充
+		b.usagePage(pageButton).usageRange(1, MouseButtons).logical(0, 1).
+			report(1, MouseButtons).input(flagData | flagVariable | flagAbsolute)
+		b.report(8-MouseButtons, 1).input(flagConstant)
+
+		if reportID == MouseReportRelative {
+			// X、Y、滚轮
+			b.usagePage(pageGenericDesktop).usage(0x30).usage(0x31).usage(0x38).logical(-127, 127).
+				report(8, 3).input(flagData | flagVariable | flagRelative)
+		} else {
+			// 绝对 X、Y
+			b.usagePage(pageGenericDesktop).usage(0x30).usage(0x31).logical(0, MouseAbsMax).
+				report(16, 2).input(flagData | flagVariable | flagAbsolute)
+			// 滚轮
+			b.usage(0x38).logical(-127, 127).report(8, 1).input(flagData | flagVariable | flagRelative)
+		}
+
+		// 水平滚动
+		b.usagePage(pageConsumer).usage(usageACPan).report(8, 1).input(flagData | flagVariable | flagRelative)
+		b.end().end()
+	}
+	return b
+}
+
+// mouseFunction 返回鼠标 function 属性
+func mouseFunction() FunctionSpec {
+	b := mouseDescriptor()
+	return FunctionSpec{
+		Kind:         KindMouse,
+		ReportLength: b.maxInputLength(),
+		ReportDesc:   b.buf,
+	}
+}
+
+// EncodeMouseRelative 构造相对移动报文
+func EncodeMouseRelative(buttons byte, dx, dy, wheel, pan int8) []byte {
+	return []byte{MouseReportRelative, buttons, byte(dx), byte(dy), byte(wheel), byte(pan)}
+}
+
+// EncodeMouseAbsolute 构造绝对定位报文，坐标范围 0-MouseAbsMax
+func EncodeMouseAbsolute(buttons byte, x, y uint16, wheel, pan int8) []byte {
+	report := make([]byte, 8)
+	report[0] = MouseReportAbsolute
+	report[1] = buttons
+	binary.LittleEndian.PutUint16(report[2:], x)
+	binary.LittleEndian.PutUint16(report[4:], y)
+	report[6] = byte(wheel)
+	report[7] = byte(pan)
+	return report
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"pi-keyboard/act"
+	"pi-keyboard/decoder"
+	"pi-keyboard/hid"
+	"strings"
+	"time"
+)
+
+// runHostSimCommand 处理 host-sim 子命令：读取报文文件或 FIFO，打印主机会收到的按键和文本
+func runHostSimCommand(args []string) error {
+	flags := flag.NewFlagSet("host-sim", flag.ExitOnError)
+	input := flags.String("input", "", "报文文件或 FIFO 路径（服务以 -file-sink -output 写入）")
+	layoutName := flags.String("layout", act.DefaultLayoutName, "主机键盘布局 (us, uk, de, fr, jp) 或布局文件路径 (.json)")
+	features := flags.String("hid-features", hid.DefaultFeatures().String(), "HID 特性，须与服务的 -hid-features 一致")
+	protocol := flags.String("protocol", "report", "主机初始使用的 HID 协议 (boot, report)，NKRO 模式下决定报文长度")
+	protocolFile := flags.String("hid-protocol-file", "", "与服务相同的 SET_PROTOCOL 协议文件，每份报文按文件中的协议切分")
+	quiet := flags.Bool("quiet", false, "只打印文本，不打印按键边沿")
+	flags.Usage = func() {
+		fmt.Println("用法:")
+		fmt.Printf("  %s host-sim -input <文件或 FIFO> [选项]\n", os.Args[0])
+		fmt.Println()
+		fmt.Println("示例:")
+		fmt.Println("  mkfifo /tmp/hidg0.fifo")
+		fmt.Printf("  %s host-sim -input /tmp/hidg0.fifo &\n", os.Args[0])
+		fmt.Printf("  %s -driver linux_otg -output /tmp/hidg0.fifo -file-sink\n", os.Args[0])
+		fmt.Println()
+		fmt.Println("选项:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *input == "" {
+		flags.Usage()
+		return fmt.Errorf("缺少 -input 参数")
+	}
+	layout, err := act.ResolveLayout(*layoutName)
+	if err != nil {
+		return err
+	}
+	parsed, err := hid.ParseFeatures(*features)
+	if err != nil {
+		return err
+	}
+	initial, err := hid.ParseProtocol(*protocol)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(*input)
+	if err != nil {
+		return err
+	}
+	isFIFO := info.Mode()&os.ModeNamedPipe != 0
+
+	dec := decoder.New(layout, parsed)
+	dec.SetProtocol(initial)
+	if *protocolFile != "" {
+		dec.SetProtocolSource(func() (byte, bool) {
+			return readProtocolFile(*protocolFile)
+		})
+	}
+	fmt.Fprintf(os.Stderr, "[HOST-SIM] 读取 %s（布局 %s，报文长度 %d）\n", *input, layout.Name, dec.ReportLength())
+
+	// Ctrl+C 时打印收到的文本
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		printHostText(dec)
+		os.Exit(0)
+	}()
+
+	start := time.Now()
+	printed := 0
+	handle := func(report []byte, edges []decoder.Edge) {
+		text := []rune(dec.Text())
+		if *quiet {
+			if len(text) > printed {
+				fmt.Print(string(text[printed:]))
+			}
+		} else if len(edges) > 0 {
+			names := make([]string, len(edges))
+			for i, edge := range edges {
+				names[i] = edge.String()
+			}
+			line := fmt.Sprintf("[%8.3fs] % x  %s", time.Since(start).Seconds(), report, strings.Join(names, " "))
+			if len(text) > printed {
+				line += fmt.Sprintf("  => %q", string(text[printed:]))
+			}
+			fmt.Println(line)
+		}
+		printed = len(text)
+	}
+
+	for {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		err = dec.Stream(file, handle)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		// FIFO 的写端关闭后继续等待下一个写端，普通文件读到末尾即结束
+		if !isFIFO {
+			break
+		}
+	}
+	printHostText(dec)
+	return nil
+}
+
+// readProtocolFile 读取协议文件，文件不存在或内容无效时 ok 为 false
+func readProtocolFile(path string) (byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	protocol, err := hid.ParseProtocol(strings.TrimSpace(string(data)))
+	return protocol, err == nil
+}
+
+// printHostText 打印主机收到的完整文本
+func printHostText(dec *decoder.Decoder) {
+	fmt.Println()
+	fmt.Printf("主机收到的文本: %q\n", dec.Text())
+	if rollovers := dec.Rollovers(); rollovers > 0 {
+		fmt.Printf("ErrorRollOver 报文: %d\n", rollovers)
+	}
+}
@@ -0,0 +1,377 @@
+package macro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"pi-keyboard/act"
+)
+
+// MaxScriptSize 请求中脚本的最大字节数
+const MaxScriptSize = 64 << 10
+
+// RunRequest POST /macro/run 的 JSON 请求体
+type RunRequest struct {
+	Script string            `json:"script"`
+	Vars   map[string]string `json:"vars,omitempty"`
+	Async  bool              `json:"async,omitempty"` // 为 true 时排队后立即返回 processing
+}
+
+// errorResponse 脚本错误，带错误位置
+type errorResponse struct {
+	Error  string `json:"error,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// runResponse 运行结果，出错时带错误位置
+type runResponse struct {
+	Steps      int   `json:"steps"`
+	DurationMs int64 `json:"duration_ms"`
+	errorResponse
+}
+
+// Service 宏的 HTTP 接口，宏与批量按键在键盘执行器上排队执行
+type Service struct {
+	keyboard *act.Keyboard
+	store    *Store // 为 nil 时不提供 /macros 接口
+}
+
+// NewService 创建宏服务，store 为宏库（可为 nil）
+func NewService(keyboard *act.Keyboard, store *Store) *Service {
+	return &Service{keyboard: keyboard, store: store}
+}
+
+// runner 创建绑定当前驱动的执行器
+func (s *Service) runner() *Runner {
+	return NewRunner(s.keyboard.Driver())
+}
+
+// RunHandler 执行宏脚本
+// 请求体为脚本文本，或 JSON {"script": "...", "vars": {...}, "async": false}；
+// 同步执行时返回 {"steps", "duration_ms"}，客户端断开时停止执行；脚本错误返回 400 和错误行列
+func (s *Service) RunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := decodeRunRequest(w, r)
+	if err != nil {
+		http.Error(w, "请求解析失败: "+err.Error(), 400)
+		return
+	}
+	if strings.TrimSpace(req.Script) == "" {
+		http.Error(w, "脚本为空", 400)
+		return
+	}
+	s.run(w, r, "macro", req)
+}
+
+// run 解析、校验并排队执行脚本，写入响应
+func (s *Service) run(w http.ResponseWriter, r *http.Request, name string, req *RunRequest) {
+	clientIP := r.RemoteAddr
+	script, err := s.compile(req.Script)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, Result{}, err)
+		return
+	}
+
+	// 异步执行与请求生命周期无关，同步执行在客户端断开时取消
+	reqCtx := r.Context()
+	if req.Async {
+		reqCtx = context.Background()
+	}
+	var result Result
+	done, err := s.keyboard.RunExclusive(name, func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(reqCtx, cancel)
+		defer stop()
+
+		log.Printf("[MACRO] 开始执行 %s - %s", name, clientIP)
+		var runErr error
+		result, runErr = s.runner().Run(ctx, script, req.Vars)
+		if runErr != nil {
+			log.Printf("[MACRO] %s 执行失败: %v（%d步）", name, runErr, result.Steps)
+		} else {
+			log.Printf("[MACRO] %s 执行完成: %d步, 耗时 %v", name, result.Steps, result.Duration)
+		}
+		return runErr
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.Async {
+		io.WriteString(w, "processing")
+		return
+	}
+	select {
+	case err = <-done:
+		writeResult(w, runErrorStatus(err), result, err)
+	case <-r.Context().Done():
+		// 客户端已断开，宏在执行器中被取消
+	}
+}
+
+// compile 解析脚本并检查当前驱动是否支持其中的字面量按键
+func (s *Service) compile(src string) (*Script, error) {
+	script, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.runner().Validate(script); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// decodeRunRequest 读取请求体：JSON 对象或纯文本脚本
+func decodeRunRequest(w http.ResponseWriter, r *http.Request) (*RunRequest, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxScriptSize))
+	if err != nil {
+		return nil, err
+	}
+	req := &RunRequest{}
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") ||
+		strings.HasPrefix(strings.TrimSpace(string(data)), "{")
+	if isJSON {
+		if err := json.Unmarshal(data, req); err != nil {
+			return nil, err
+		}
+	} else {
+		req.Script = string(data)
+	}
+	if r.URL.Query().Get("async") == "true" || r.URL.Query().Get("async") == "1" {
+		req.Async = true
+	}
+	return req, nil
+}
+
+// runErrorStatus 运行错误对应的 HTTP 状态码
+func runErrorStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, act.ErrHostNotReady), errors.Is(err, act.ErrHostWaitTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrStepLimit):
+		return http.StatusBadRequest
+	default:
+		var macroErr *Error
+		if errors.As(err, &macroErr) && macroErr.Err == nil {
+			return http.StatusBadRequest // 运行时才发现的脚本错误，如未定义的变量
+		}
+		return http.StatusInternalServerError
+	}
+}
+
+// writeResult 以 JSON 写入运行结果
+func writeResult(w http.ResponseWriter, status int, result Result, err error) {
+	writeJSON(w, status, runResponse{
+		Steps:         result.Steps,
+		DurationMs:    result.Duration.Milliseconds(),
+		errorResponse: newErrorResponse(err),
+	})
+}
+
+// newErrorResponse 转换错误，脚本错误带行列位置
+func newErrorResponse(err error) errorResponse {
+	if err == nil {
+		return errorResponse{}
+	}
+	var macroErr *Error
+	if errors.As(err, &macroErr) {
+		return errorResponse{Error: macroErr.Msg, Line: macroErr.Line, Column: macroErr.Column}
+	}
+	return errorResponse{Error: err.Error()}
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// MacrosHandler 宏库接口
+//
+//	GET    /macros             列出所有宏
+//	GET    /macros/{name}      读取宏
+//	PUT    /macros/{name}      保存宏（请求体为脚本文本或 {"script": "..."}），保存前按当前驱动校验
+//	DELETE /macros/{name}      删除宏
+//	POST   /macros/{name}/run  执行宏，参数通过 {"vars": {...}} 或查询参数传入
+func (s *Service) MacrosHandler(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		http.Error(w, "未启用宏库", http.StatusNotImplemented)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/macros"), "/")
+	name, action, _ := strings.Cut(path, "/")
+
+	switch {
+	case name == "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "只支持 GET", http.StatusMethodNotAllowed)
+			return
+		}
+		s.listMacros(w)
+	case action == "run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "只支持 POST", http.StatusMethodNotAllowed)
+			return
+		}
+		s.runMacro(w, r, name)
+	case action != "":
+		http.NotFound(w, r)
+	case r.Method == http.MethodGet:
+		s.getMacro(w, name)
+	case r.Method == http.MethodPut:
+		s.putMacro(w, r, name)
+	case r.Method == http.MethodDelete:
+		s.deleteMacro(w, name)
+	default:
+		http.Error(w, "只支持 GET、PUT 和 DELETE", http.StatusMethodNotAllowed)
+	}
+}
+
+// check 按当前驱动检查宏，不能执行时填写 Error
+func (s *Service) check(info *Info, script string) {
+	if info.Error != "" {
+		return
+	}
+	if _, err := s.compile(script); err != nil {
+		info.Error = err.Error()
+	}
+}
+
+// listMacros 列出宏
+func (s *Service) listMacros(w http.ResponseWriter) {
+	infos, err := s.store.List()
+	if err != nil {
+		http.Error(w, "读取宏库失败: "+err.Error(), 500)
+		return
+	}
+	for i := range infos {
+		if m, err := s.store.Get(infos[i].Name); err == nil {
+			s.check(&infos[i], m.Script)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"driver": s.keyboard.Driver().GetDriverType(),
+		"macros": infos,
+	})
+}
+
+// getMacro 读取宏
+func (s *Service) getMacro(w http.ResponseWriter, name string) {
+	m, ok := s.lookup(w, name)
+	if !ok {
+		return
+	}
+	s.check(&m.Info, m.Script)
+	writeJSON(w, http.StatusOK, m)
+}
+
+// lookup 读取宏，失败时写入 404 或 500
+func (s *Service) lookup(w http.ResponseWriter, name string) (*Macro, bool) {
+	m, err := s.store.Get(name)
+	switch {
+	case errors.Is(err, ErrMacroNotFound):
+		http.Error(w, "宏不存在: "+name, http.StatusNotFound)
+		return nil, false
+	case err != nil:
+		http.Error(w, "读取宏失败: "+err.Error(), 500)
+		return nil, false
+	}
+	return m, true
+}
+
+// putMacro 校验并保存宏，脚本错误返回 400 和错误行列
+func (s *Service) putMacro(w http.ResponseWriter, r *http.Request, name string) {
+	if !ValidName(name) {
+		http.Error(w, "无效的宏名称: "+name+"（只能包含字母、数字、-、_）", 400)
+		return
+	}
+	req, err := decodeRunRequest(w, r)
+	if err != nil {
+		http.Error(w, "请求解析失败: "+err.Error(), 400)
+		return
+	}
+	if strings.TrimSpace(req.Script) == "" {
+		http.Error(w, "脚本为空", 400)
+		return
+	}
+	if _, err := s.compile(req.Script); err != nil {
+		writeJSON(w, http.StatusBadRequest, newErrorResponse(err))
+		return
+	}
+
+	if err := s.store.Put(name, req.Script); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrReadOnlyStore) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, "保存宏失败: "+err.Error(), status)
+		return
+	}
+	log.Printf("[MACRO] 已保存宏 %s - %s", name, r.RemoteAddr)
+	s.getMacro(w, name)
+}
+
+// deleteMacro 删除宏
+func (s *Service) deleteMacro(w http.ResponseWriter, name string) {
+	switch err := s.store.Delete(name); {
+	case err == nil:
+		log.Printf("[MACRO] 已删除宏 %s", name)
+		io.WriteString(w, "ok")
+	case errors.Is(err, ErrMacroNotFound):
+		http.Error(w, "宏不存在: "+name, http.StatusNotFound)
+	case errors.Is(err, ErrBuiltinMacro):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "删除宏失败: "+err.Error(), 500)
+	}
+}
+
+// runMacro 执行宏库中的宏，缺少参数时返回 400
+func (s *Service) runMacro(w http.ResponseWriter, r *http.Request, name string) {
+	m, ok := s.lookup(w, name)
+	if !ok {
+		return
+	}
+	req, err := decodeRunRequest(w, r)
+	if err != nil {
+		http.Error(w, "请求解析失败: "+err.Error(), 400)
+		return
+	}
+	// 查询参数（async 除外）也作为变量，JSON 中的 vars 优先
+	vars := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		if key != "async" && len(values) > 0 {
+			vars[key] = values[0]
+		}
+	}
+	for key, value := range req.Vars {
+		vars[key] = value
+	}
+
+	var missing []string
+	for _, param := range m.Params {
+		if _, ok := vars[param]; !ok {
+			missing = append(missing, param)
+		}
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "缺少参数: " + strings.Join(missing, ", ")})
+		return
+	}
+
+	s.run(w, r, "macro "+name, &RunRequest{Script: m.Script, Vars: vars, Async: req.Async})
+}
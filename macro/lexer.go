@@ -0,0 +1,174 @@
+package macro
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokNewline           // 换行或分号
+	tokWord              // 未加引号的单词：命令、按键、时长、变量引用
+	tokString            // 双引号字符串（已处理转义）
+	tokLBrace
+	tokRBrace
+)
+
+// token 词法单元
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+// describe 返回用于错误信息的词法单元描述
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "脚本结尾"
+	case tokNewline:
+		return "行尾"
+	case tokLBrace:
+		return "{"
+	case tokRBrace:
+		return "}"
+	case tokString:
+		return fmt.Sprintf("字符串 %q", t.text)
+	default:
+		return t.text
+	}
+}
+
+// lexer 把脚本切分为词法单元，记录每个单元的行列位置
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+// tokenize 切分整个脚本
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var tokens []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// peek 返回当前字符
+func (l *lexer) peek() rune {
+	if l.off >= len(l.src) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return r
+}
+
+// advance 前进一个字符并更新行列
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+// next 读取下一个词法单元
+func (l *lexer) next() (token, error) {
+	for l.off < len(l.src) {
+		switch r := l.peek(); {
+		case r == ' ' || r == '\t' || r == '\r':
+			l.advance()
+		case r == '#':
+			for l.off < len(l.src) && l.peek() != '\n' {
+				l.advance()
+			}
+		default:
+			goto found
+		}
+	}
+	return token{kind: tokEOF, pos: Pos{l.line, l.col}}, nil
+
+found:
+	pos := Pos{l.line, l.col}
+	switch r := l.peek(); r {
+	case '\n', ';':
+		l.advance()
+		return token{kind: tokNewline, text: string(r), pos: pos}, nil
+	case '{':
+		l.advance()
+		return token{kind: tokLBrace, text: "{", pos: pos}, nil
+	case '}':
+		l.advance()
+		return token{kind: tokRBrace, text: "}", pos: pos}, nil
+	case '"':
+		return l.readString(pos)
+	default:
+		return l.readWord(pos), nil
+	}
+}
+
+// readString 读取双引号字符串，支持 \n \t \" \\ \$ 转义
+func (l *lexer) readString(pos Pos) (token, error) {
+	l.advance() // 开头的引号
+	var b strings.Builder
+	for {
+		if l.off >= len(l.src) || l.peek() == '\n' {
+			return token{}, errorf(pos, "字符串缺少结尾的引号")
+		}
+		r := l.advance()
+		switch r {
+		case '"':
+			return token{kind: tokString, text: b.String(), pos: pos}, nil
+		case '\\':
+			escPos := Pos{l.line, l.col - 1}
+			if l.off >= len(l.src) {
+				return token{}, errorf(pos, "字符串缺少结尾的引号")
+			}
+			switch e := l.advance(); e {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case '"', '\\':
+				b.WriteRune(e)
+			case '$':
+				// 转义的 $ 不参与变量替换，用不可见的占位符保留到展开时
+				b.WriteRune(escapedDollar)
+			default:
+				return token{}, errorf(escPos, "未知的转义字符 \\%c", e)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// readWord 读取到空白、换行、分号、花括号或引号为止的单词
+func (l *lexer) readWord(pos Pos) token {
+	start := l.off
+	for l.off < len(l.src) {
+		switch l.peek() {
+		case ' ', '\t', '\r', '\n', ';', '{', '}', '"':
+			return token{kind: tokWord, text: l.src[start:l.off], pos: pos}
+		}
+		l.advance()
+	}
+	return token{kind: tokWord, text: l.src[start:l.off], pos: pos}
+}
@@ -0,0 +1,554 @@
+// Package macro 实现按键宏脚本：一个驱动 act.KeyboardDriver 的小型脚本语言
+//
+// 脚本按行书写，换行或分号分隔语句，# 开始注释：
+//
+//	set user = "admin"
+//	waitfor host-configured timeout 10s
+//	type "${user}\t"
+//	press ctrl+a 80ms
+//	hold shift { press tab; press tab }
+//	repeat 3 { press down; wait 200ms }
+//	press enter
+//
+// 时长为 Go 时长格式（500ms、1.5s），纯数字按毫秒计；按键使用 act 的标准名、别名
+// 或 "+" 连接的组合键；$name 和字符串中的 ${name} 引用变量。
+package macro
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 脚本限制
+const (
+	MaxRepeat      = 10000  // repeat 的最大次数
+	MaxSteps       = 100000 // 单次运行最多执行的步数（按键、输入、等待、赋值和每次循环迭代各计一步）
+	MaxWait        = 10 * time.Minute
+	maxBlockDepth  = 32
+	escapedDollar  = '\uE000' // 字符串中 \$ 的占位符，展开变量后还原为 $
+	hostTimeoutArg = "timeout"
+)
+
+// waitfor 支持的主机状态
+var hostStates = map[string]string{
+	"host-configured":   "configured",
+	"host-connected":    "configured", // 已连接即主机完成枚举、可以收发按键
+	"host-suspended":    "suspended",
+	"host-disconnected": "not attached",
+}
+
+// Pos 脚本中的位置（从 1 开始）
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error 带行列位置的脚本错误，解析错误和运行错误都使用该类型
+type Error struct {
+	Pos
+	Msg string
+	Err error // 运行时驱动等返回的底层错误
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("第 %d 行第 %d 列: %s", e.Line, e.Column, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorf 创建指定位置的脚本错误
+func errorf(pos Pos, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// wrapError 用位置包装运行时错误
+func wrapError(pos Pos, err error) *Error {
+	var macroErr *Error
+	if errors.As(err, &macroErr) {
+		return macroErr
+	}
+	return &Error{Pos: pos, Msg: err.Error(), Err: err}
+}
+
+// Value 参数值：字面量、带引号的字符串或 $变量
+type Value struct {
+	Pos    Pos
+	Text   string
+	Quoted bool
+}
+
+// IsVar 判断是否为 $name 变量引用
+func (v Value) IsVar() bool {
+	return !v.Quoted && strings.HasPrefix(v.Text, "$")
+}
+
+// Stmt 脚本语句
+type Stmt interface {
+	Position() Pos
+}
+
+// TypeStmt type "文本"：按当前布局输入字符串
+type TypeStmt struct {
+	Pos  Pos
+	Text Value
+}
+
+// PressStmt press KEY [DURATION]：按下并释放按键或组合键
+type PressStmt struct {
+	Pos      Pos
+	Key      Value
+	Duration *Value // 为 nil 时使用 Runner.PressDuration
+}
+
+// HoldStmt hold KEY... { ... }：按住按键执行块，块结束（包括出错）后逆序释放
+type HoldStmt struct {
+	Pos  Pos
+	Keys []Value
+	Body []Stmt
+}
+
+// WaitStmt wait DURATION
+type WaitStmt struct {
+	Pos      Pos
+	Duration Value
+}
+
+// RepeatStmt repeat COUNT { ... }
+type RepeatStmt struct {
+	Pos   Pos
+	Count Value
+	Body  []Stmt
+}
+
+// WaitForStmt waitfor STATE [timeout DURATION]：等待 USB 主机进入指定状态
+type WaitForStmt struct {
+	Pos     Pos
+	State   string // UDC 状态，如 configured
+	Name    string // 脚本中的写法，如 host-connected
+	Timeout *Value // 为 nil 时使用 Runner.HostTimeout
+}
+
+// SetStmt set NAME = VALUE
+type SetStmt struct {
+	Pos   Pos
+	Name  string
+	Value Value
+}
+
+func (s *TypeStmt) Position() Pos    { return s.Pos }
+func (s *PressStmt) Position() Pos   { return s.Pos }
+func (s *HoldStmt) Position() Pos    { return s.Pos }
+func (s *WaitStmt) Position() Pos    { return s.Pos }
+func (s *RepeatStmt) Position() Pos  { return s.Pos }
+func (s *WaitForStmt) Position() Pos { return s.Pos }
+func (s *SetStmt) Position() Pos     { return s.Pos }
+
+// Script 解析后的脚本
+type Script struct {
+	Stmts []Stmt
+}
+
+// parser 递归下降解析器
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse 解析脚本文本，错误为 *Error
+func Parse(src string) (*Script, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmts, err := p.parseBlock(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{Stmts: stmts}, nil
+}
+
+// peek 返回当前词法单元
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next 取出当前词法单元
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseBlock 解析语句序列，inBraces 为 true 时以 } 结束
+func (p *parser) parseBlock(inBraces bool) ([]Stmt, error) {
+	var stmts []Stmt
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case tokNewline:
+			p.next()
+			continue
+		case tokEOF:
+			if inBraces {
+				return nil, errorf(tok.pos, "缺少 }")
+			}
+			return stmts, nil
+		case tokRBrace:
+			if !inBraces {
+				return nil, errorf(tok.pos, "多余的 }")
+			}
+			p.next()
+			return stmts, nil
+		case tokWord:
+			stmt, err := p.parseStmt()
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt)
+		default:
+			return nil, errorf(tok.pos, "语句应以命令开头，得到 %s", tok.describe())
+		}
+	}
+}
+
+// parseBody 解析 { ... } 块
+func (p *parser) parseBody(cmd token) ([]Stmt, error) {
+	tok := p.next()
+	if tok.kind != tokLBrace {
+		return nil, errorf(tok.pos, "%s 后应为 {，得到 %s", cmd.text, tok.describe())
+	}
+	if p.depth >= maxBlockDepth {
+		return nil, errorf(tok.pos, "块嵌套超过 %d 层", maxBlockDepth)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	return p.parseBlock(true)
+}
+
+// endStmt 语句结束：换行、分号、} 或脚本结尾
+func (p *parser) endStmt(cmd token) error {
+	switch tok := p.peek(); tok.kind {
+	case tokNewline:
+		p.next()
+		return nil
+	case tokRBrace, tokEOF:
+		return nil
+	default:
+		return errorf(tok.pos, "%s 的多余参数: %s", cmd.text, tok.describe())
+	}
+}
+
+// value 读取一个参数
+func (p *parser) value(cmd token, what string) (Value, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokWord, tokString:
+		p.next()
+		return Value{Pos: tok.pos, Text: tok.text, Quoted: tok.kind == tokString}, nil
+	default:
+		return Value{}, errorf(tok.pos, "%s 缺少%s", cmd.text, what)
+	}
+}
+
+// optionalValue 读取可选参数，当前已到语句结尾时返回 nil
+func (p *parser) optionalValue() *Value {
+	tok := p.peek()
+	if tok.kind != tokWord && tok.kind != tokString {
+		return nil
+	}
+	p.next()
+	return &Value{Pos: tok.pos, Text: tok.text, Quoted: tok.kind == tokString}
+}
+
+// parseStmt 解析一条语句
+func (p *parser) parseStmt() (Stmt, error) {
+	cmd := p.next()
+
+	// NAME=VALUE 形式的赋值
+	if name, rest, ok := strings.Cut(cmd.text, "="); ok {
+		return p.parseAssign(cmd, name, rest)
+	}
+
+	var stmt Stmt
+	switch strings.ToLower(cmd.text) {
+	case "type":
+		text, err := p.value(cmd, "要输入的文本")
+		if err != nil {
+			return nil, err
+		}
+		stmt = &TypeStmt{Pos: cmd.pos, Text: text}
+
+	case "press":
+		key, err := p.value(cmd, "按键")
+		if err != nil {
+			return nil, err
+		}
+		s := &PressStmt{Pos: cmd.pos, Key: key, Duration: p.optionalValue()}
+		if err := checkDuration(s.Duration); err != nil {
+			return nil, err
+		}
+		stmt = s
+
+	case "hold":
+		s := &HoldStmt{Pos: cmd.pos}
+		for p.peek().kind == tokWord || p.peek().kind == tokString {
+			key, _ := p.value(cmd, "按键")
+			s.Keys = append(s.Keys, key)
+		}
+		if len(s.Keys) == 0 {
+			return nil, errorf(p.peek().pos, "hold 缺少按键")
+		}
+		body, err := p.parseBody(cmd)
+		if err != nil {
+			return nil, err
+		}
+		s.Body = body
+		stmt = s
+
+	case "wait", "sleep":
+		d, err := p.value(cmd, "时长")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDuration(&d); err != nil {
+			return nil, err
+		}
+		stmt = &WaitStmt{Pos: cmd.pos, Duration: d}
+
+	case "repeat":
+		count, err := p.value(cmd, "次数")
+		if err != nil {
+			return nil, err
+		}
+		if !count.IsVar() {
+			if _, err := parseCount(count); err != nil {
+				return nil, err
+			}
+		}
+		body, err := p.parseBody(cmd)
+		if err != nil {
+			return nil, err
+		}
+		stmt = &RepeatStmt{Pos: cmd.pos, Count: count, Body: body}
+
+	case "waitfor":
+		name, err := p.value(cmd, "主机状态")
+		if err != nil {
+			return nil, err
+		}
+		state, ok := hostStates[strings.ToLower(name.Text)]
+		if !ok || name.Quoted {
+			return nil, errorf(name.Pos, "未知的主机状态: %s（可选 host-connected、host-configured、host-suspended、host-disconnected）", name.Text)
+		}
+		s := &WaitForStmt{Pos: cmd.pos, State: state, Name: strings.ToLower(name.Text)}
+		if tok := p.peek(); tok.kind == tokWord && strings.EqualFold(tok.text, hostTimeoutArg) {
+			p.next()
+			timeout, err := p.value(tok, "时长")
+			if err != nil {
+				return nil, err
+			}
+			if err := checkDuration(&timeout); err != nil {
+				return nil, err
+			}
+			s.Timeout = &timeout
+		}
+		stmt = s
+
+	case "set":
+		return p.parseSet(cmd)
+
+	default:
+		return nil, errorf(cmd.pos, "未知的命令: %s", cmd.text)
+	}
+
+	if err := p.endStmt(cmd); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseSet 解析 set NAME = VALUE（也接受 set NAME=VALUE、set NAME VALUE）
+func (p *parser) parseSet(cmd token) (Stmt, error) {
+	nameTok := p.next()
+	if nameTok.kind != tokWord {
+		return nil, errorf(nameTok.pos, "set 缺少变量名")
+	}
+	name, rest, hasEq := strings.Cut(nameTok.text, "=")
+	if hasEq {
+		return p.parseAssign(nameTok, name, rest)
+	}
+	if tok := p.peek(); tok.kind == tokWord && strings.HasPrefix(tok.text, "=") {
+		p.next()
+		if rest := tok.text[1:]; rest != "" {
+			return p.finishSet(cmd, nameTok.pos, name, Value{Pos: Pos{tok.pos.Line, tok.pos.Column + 1}, Text: rest})
+		}
+	}
+	value, err := p.value(cmd, "变量值")
+	if err != nil {
+		return nil, err
+	}
+	return p.finishSet(cmd, nameTok.pos, name, value)
+}
+
+// parseAssign 解析 NAME=VALUE 形式，值可以紧跟在等号后，也可以是后面的字符串
+func (p *parser) parseAssign(tok token, name, rest string) (Stmt, error) {
+	if rest != "" {
+		col := tok.pos.Column + len([]rune(name)) + 1
+		return p.finishSet(tok, tok.pos, name, Value{Pos: Pos{tok.pos.Line, col}, Text: rest})
+	}
+	value, err := p.value(tok, "变量值")
+	if err != nil {
+		return nil, err
+	}
+	return p.finishSet(tok, tok.pos, name, value)
+}
+
+// finishSet 校验变量名并结束赋值语句
+func (p *parser) finishSet(cmd token, pos Pos, name string, value Value) (Stmt, error) {
+	name = strings.TrimPrefix(name, "$")
+	if !validName(name) {
+		return nil, errorf(pos, "无效的变量名: %q", name)
+	}
+	if err := p.endStmt(cmd); err != nil {
+		return nil, err
+	}
+	return &SetStmt{Pos: pos, Name: name, Value: value}, nil
+}
+
+// validName 变量名由字母、数字、下划线组成，不以数字开头
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checkDuration 解析时检查字面量时长，变量引用留到运行时检查
+func checkDuration(v *Value) error {
+	if v == nil || v.IsVar() {
+		return nil
+	}
+	_, err := parseDuration(*v)
+	return err
+}
+
+// parseDuration 解析时长，纯数字按毫秒计
+func parseDuration(v Value) (time.Duration, error) {
+	text := strings.TrimSpace(v.Text)
+	var d time.Duration
+	if ms, err := strconv.ParseFloat(text, 64); err == nil {
+		d = time.Duration(ms * float64(time.Millisecond))
+	} else if d, err = time.ParseDuration(text); err != nil {
+		return 0, errorf(v.Pos, "无效的时长: %q", v.Text)
+	}
+	if d < 0 || d > MaxWait {
+		return 0, errorf(v.Pos, "时长须在 0 到 %v 之间: %s", MaxWait, v.Text)
+	}
+	return d, nil
+}
+
+// parseCount 解析 repeat 次数
+func parseCount(v Value) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(v.Text))
+	if err != nil {
+		return 0, errorf(v.Pos, "无效的次数: %q", v.Text)
+	}
+	if n < 0 || n > MaxRepeat {
+		return 0, errorf(v.Pos, "次数须在 0 到 %d 之间: %d", MaxRepeat, n)
+	}
+	return n, nil
+}
+
+// Params 返回脚本引用但没有用 set 赋值的变量（按首次出现顺序），即运行时须由调用方提供的参数
+func (s *Script) Params() []string {
+	assigned := make(map[string]bool)
+	var refs []string
+	seen := make(map[string]bool)
+	ref := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			refs = append(refs, name)
+		}
+	}
+	walkValue := func(v *Value) {
+		switch {
+		case v == nil:
+		case v.IsVar():
+			ref(strings.TrimPrefix(v.Text, "$"))
+		case v.Quoted:
+			for _, name := range interpolatedNames(v.Text) {
+				ref(name)
+			}
+		}
+	}
+	var walk func(stmts []Stmt)
+	walk = func(stmts []Stmt) {
+		for _, stmt := range stmts {
+			switch st := stmt.(type) {
+			case *TypeStmt:
+				walkValue(&st.Text)
+			case *PressStmt:
+				walkValue(&st.Key)
+				walkValue(st.Duration)
+			case *HoldStmt:
+				for i := range st.Keys {
+					walkValue(&st.Keys[i])
+				}
+				walk(st.Body)
+			case *WaitStmt:
+				walkValue(&st.Duration)
+			case *RepeatStmt:
+				walkValue(&st.Count)
+				walk(st.Body)
+			case *WaitForStmt:
+				walkValue(st.Timeout)
+			case *SetStmt:
+				walkValue(&st.Value)
+				assigned[st.Name] = true
+			}
+		}
+	}
+	walk(s.Stmts)
+
+	params := make([]string, 0, len(refs))
+	for _, name := range refs {
+		if !assigned[name] {
+			params = append(params, name)
+		}
+	}
+	return params
+}
+
+// interpolatedNames 返回字符串中 ${name} 引用的变量名
+func interpolatedNames(text string) []string {
+	var names []string
+	for {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			return names
+		}
+		names = append(names, text[start+2:start+end])
+		text = text[start+end+1:]
+	}
+}
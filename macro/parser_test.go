@@ -0,0 +1,64 @@
+package macro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src          string
+		line, column int
+	}{
+		{"press", 1, 6},
+		{"type \"abc", 1, 6},
+		{"type \"a\\qb\"", 1, 8},
+		{"repeat 3 {\n  press a\n", 3, 1},
+		{"press a }", 1, 9},
+		{"jump a", 1, 1},
+		{"wait 1h", 1, 6},
+		{"repeat 10001 { }", 1, 8},
+		{"press a b", 1, 9},
+		{"waitfor host-gone", 1, 9},
+		{"set 1x = 2", 1, 5},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var macroErr *Error
+		if !errors.As(err, &macroErr) {
+			t.Errorf("Parse(%q) 期望 *Error，得到 %v", tt.src, err)
+			continue
+		}
+		if macroErr.Line != tt.line || macroErr.Column != tt.column {
+			t.Errorf("Parse(%q) 错误位置 %d:%d，期望 %d:%d（%v）", tt.src, macroErr.Line, macroErr.Column, tt.line, tt.column, err)
+		}
+	}
+}
+
+func TestParseStatements(t *testing.T) {
+	src := "# 登录\nwaitfor host-configured timeout 5s\nhold shift { press a 20ms }; repeat 2 { wait 1s }\nset name = \"x\"\ntype \"${user}\\n\""
+	script, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script.Stmts) != 5 {
+		t.Fatalf("语句数 = %d，期望 5", len(script.Stmts))
+	}
+	if _, ok := script.Stmts[0].(*WaitForStmt); !ok {
+		t.Errorf("第 1 条语句为 %T", script.Stmts[0])
+	}
+	hold, ok := script.Stmts[1].(*HoldStmt)
+	if !ok || len(hold.Keys) != 1 || len(hold.Body) != 1 {
+		t.Fatalf("第 2 条语句解析错误: %#v", script.Stmts[1])
+	}
+	if press := hold.Body[0].(*PressStmt); press.Duration == nil || press.Duration.Text != "20ms" {
+		t.Errorf("press 时长解析错误: %#v", press)
+	}
+	if pos := script.Stmts[2].Position(); pos != (Pos{Line: 3, Column: 30}) {
+		t.Errorf("repeat 位置 = %+v", pos)
+	}
+	if params := script.Params(); !reflect.DeepEqual(params, []string{"user"}) {
+		t.Errorf("Params() = %v，期望 [user]", params)
+	}
+}
@@ -0,0 +1,378 @@
+package macro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"pi-keyboard/act"
+)
+
+// 默认运行参数
+const (
+	DefaultPressDuration = 50 * time.Millisecond
+	DefaultGap           = 10 * time.Millisecond
+	DefaultHostTimeout   = 30 * time.Second
+)
+
+// ErrStepLimit 脚本执行步数超过 MaxSteps
+var ErrStepLimit = errors.New("宏执行步数超过上限")
+
+// Runner 在键盘驱动上执行宏脚本
+//
+// Runner 本身不保存运行状态，可以复用；同一驱动上的多次运行需由调用方排队，
+// 否则按键会交错（HTTP 接口通过 Keyboard.RunExclusive 排队）。
+type Runner struct {
+	Driver        act.KeyboardDriver
+	PressDuration time.Duration // press 未指定时长时的按住时间
+	Gap           time.Duration // 每个 press/type 之后的间隔
+	HostTimeout   time.Duration // waitfor 未指定 timeout 时的等待期限
+}
+
+// NewRunner 使用默认参数创建执行器
+func NewRunner(driver act.KeyboardDriver) *Runner {
+	return &Runner{
+		Driver:        driver,
+		PressDuration: DefaultPressDuration,
+		Gap:           DefaultGap,
+		HostTimeout:   DefaultHostTimeout,
+	}
+}
+
+// Result 宏运行结果
+type Result struct {
+	Steps    int           `json:"steps"`
+	Duration time.Duration `json:"-"`
+}
+
+// run 单次运行的状态
+type run struct {
+	*Runner
+	ctx   context.Context
+	vars  map[string]string
+	held  []string // 按下顺序
+	steps int
+}
+
+// Validate 检查脚本中的字面量按键是否被驱动支持，变量引用留到运行时检查
+func (r *Runner) Validate(script *Script) error {
+	return r.validate(script.Stmts)
+}
+
+func (r *Runner) validate(stmts []Stmt) error {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *PressStmt:
+			if !s.Key.IsVar() {
+				if _, err := r.resolveKeys(s.Key, s.Key.Text); err != nil {
+					return err
+				}
+			}
+		case *HoldStmt:
+			for _, key := range s.Keys {
+				if !key.IsVar() {
+					if _, err := r.resolveKeys(key, key.Text); err != nil {
+						return err
+					}
+				}
+			}
+			if err := r.validate(s.Body); err != nil {
+				return err
+			}
+		case *RepeatStmt:
+			if err := r.validate(s.Body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run 执行脚本，vars 为初始变量；ctx 取消时停止执行。
+// 无论成功与否，返回前都会释放脚本中按住的按键。错误为 *Error
+func (r *Runner) Run(ctx context.Context, script *Script, vars map[string]string) (Result, error) {
+	startTime := time.Now()
+	state := &run{Runner: r, ctx: ctx, vars: make(map[string]string, len(vars))}
+	for name, value := range vars {
+		state.vars[name] = value
+	}
+
+	err := state.exec(script.Stmts)
+	if releaseErr := state.releaseAll(); err == nil && releaseErr != nil {
+		err = releaseErr
+	}
+	return Result{Steps: state.steps, Duration: time.Since(startTime)}, err
+}
+
+// exec 依次执行语句
+func (s *run) exec(stmts []Stmt) error {
+	for _, stmt := range stmts {
+		if err := s.ctx.Err(); err != nil {
+			return wrapError(stmt.Position(), err)
+		}
+		if err := s.execStmt(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step 统计执行步数，防止失控的嵌套循环长时间占用键盘
+func (s *run) step(pos Pos) error {
+	s.steps++
+	if s.steps > MaxSteps {
+		return &Error{Pos: pos, Msg: fmt.Sprintf("%v（%d）", ErrStepLimit, MaxSteps), Err: ErrStepLimit}
+	}
+	return nil
+}
+
+// execStmt 执行单条语句
+func (s *run) execStmt(stmt Stmt) error {
+	switch st := stmt.(type) {
+	case *SetStmt:
+		if err := s.step(st.Pos); err != nil {
+			return err
+		}
+		value, err := s.expand(st.Value)
+		if err != nil {
+			return err
+		}
+		s.vars[st.Name] = value
+
+	case *TypeStmt:
+		if err := s.step(st.Pos); err != nil {
+			return err
+		}
+		text, err := s.expand(st.Text)
+		if err != nil {
+			return err
+		}
+		if err := s.Driver.Type(text); err != nil {
+			return wrapError(st.Pos, fmt.Errorf("输入失败: %w", err))
+		}
+		return s.sleep(st.Pos, s.Gap)
+
+	case *PressStmt:
+		if err := s.step(st.Pos); err != nil {
+			return err
+		}
+		keys, err := s.keys(st.Key)
+		if err != nil {
+			return err
+		}
+		duration := s.PressDuration
+		if st.Duration != nil {
+			if duration, err = s.duration(*st.Duration); err != nil {
+				return err
+			}
+		}
+		if err := act.PressChord(s.Driver, keys, duration); err != nil {
+			return wrapError(st.Pos, fmt.Errorf("按键 %s 失败: %w", strings.Join(keys, act.ChordSeparator), err))
+		}
+		return s.sleep(st.Pos, s.Gap)
+
+	case *HoldStmt:
+		return s.hold(st)
+
+	case *WaitStmt:
+		if err := s.step(st.Pos); err != nil {
+			return err
+		}
+		d, err := s.duration(st.Duration)
+		if err != nil {
+			return err
+		}
+		return s.sleep(st.Pos, d)
+
+	case *RepeatStmt:
+		countText, err := s.expand(st.Count)
+		if err != nil {
+			return err
+		}
+		count, err := parseCount(Value{Pos: st.Count.Pos, Text: countText})
+		if err != nil {
+			return err
+		}
+		// 每次迭代都计入步数并检查取消，空循环体和嵌套循环也不会长时间占用键盘
+		for i := 0; i < count; i++ {
+			if err := s.ctx.Err(); err != nil {
+				return wrapError(st.Pos, err)
+			}
+			if err := s.step(st.Pos); err != nil {
+				return err
+			}
+			if err := s.exec(st.Body); err != nil {
+				return err
+			}
+		}
+
+	case *WaitForStmt:
+		return s.waitFor(st)
+	}
+	return nil
+}
+
+// hold 按住按键执行块，块结束后逆序释放这些按键
+func (s *run) hold(st *HoldStmt) error {
+	var keys []string
+	for _, v := range st.Keys {
+		resolved, err := s.keys(v)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, resolved...)
+	}
+
+	mark := len(s.held)
+	for _, key := range keys {
+		if err := s.step(st.Pos); err != nil {
+			return err
+		}
+		if err := s.Driver.KeyDown(key); err != nil {
+			return wrapError(st.Pos, fmt.Errorf("按键 %s 按下失败: %w", key, err))
+		}
+		s.held = append(s.held, key)
+	}
+
+	err := s.exec(st.Body)
+	if releaseErr := s.releaseTo(mark); err == nil && releaseErr != nil {
+		err = wrapError(st.Pos, releaseErr)
+	}
+	return err
+}
+
+// releaseTo 逆序释放 mark 之后按下的按键
+func (s *run) releaseTo(mark int) error {
+	var err error
+	for i := len(s.held) - 1; i >= mark; i-- {
+		if uerr := s.Driver.KeyUp(s.held[i]); uerr != nil && err == nil {
+			err = fmt.Errorf("按键 %s 释放失败: %w", s.held[i], uerr)
+		}
+	}
+	s.held = s.held[:mark]
+	return err
+}
+
+// releaseAll 释放所有仍按住的按键（出错或取消时 hold 块可能未正常结束）
+func (s *run) releaseAll() error {
+	if len(s.held) == 0 {
+		return nil
+	}
+	log.Printf("[MACRO] 释放仍按住的按键: %s", strings.Join(s.held, ", "))
+	return s.releaseTo(0)
+}
+
+// waitFor 等待主机状态，驱动无法检测主机状态时跳过
+func (s *run) waitFor(st *WaitForStmt) error {
+	timeout := s.HostTimeout
+	if st.Timeout != nil {
+		var err error
+		if timeout, err = s.duration(*st.Timeout); err != nil {
+			return err
+		}
+	}
+	monitor, ok := act.HostMonitorFor(s.Driver)
+	if !ok {
+		log.Printf("[MACRO] 驱动 %s 无法检测主机状态，跳过 waitfor %s", s.Driver.GetDriverType(), st.Name)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+	if err := monitor.WaitFor(ctx, st.State); err != nil {
+		if s.ctx.Err() != nil {
+			return wrapError(st.Pos, s.ctx.Err())
+		}
+		return wrapError(st.Pos, err)
+	}
+	return nil
+}
+
+// sleep 可被取消的等待
+func (s *run) sleep(pos Pos, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-s.ctx.Done():
+		return wrapError(pos, s.ctx.Err())
+	}
+}
+
+// keys 展开变量并解析按键或组合键
+func (s *run) keys(v Value) ([]string, error) {
+	expr, err := s.expand(v)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveKeys(v, expr)
+}
+
+// resolveKeys 把按键表达式解析为驱动支持的标准按键名
+func (r *Runner) resolveKeys(v Value, expr string) ([]string, error) {
+	if act.IsChord(expr) {
+		keys, err := act.ParseChord(expr, r.Driver)
+		if err != nil {
+			return nil, wrapError(v.Pos, err)
+		}
+		return keys, nil
+	}
+	key := act.CanonicalKey(expr)
+	if key == "" || !r.Driver.IsKeySupported(key) {
+		return nil, errorf(v.Pos, "不支持的按键: %s", expr)
+	}
+	return []string{key}, nil
+}
+
+// duration 展开变量并解析时长
+func (s *run) duration(v Value) (time.Duration, error) {
+	text, err := s.expand(v)
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(Value{Pos: v.Pos, Text: text})
+}
+
+// expand 展开参数中的变量：未加引号的 $name 整体引用变量，字符串中 ${name} 内插
+func (s *run) expand(v Value) (string, error) {
+	if v.IsVar() {
+		name := strings.TrimPrefix(v.Text, "$")
+		value, ok := s.vars[name]
+		if !ok {
+			return "", errorf(v.Pos, "未定义的变量: %s", name)
+		}
+		return value, nil
+	}
+	if !v.Quoted {
+		return v.Text, nil
+	}
+
+	var b strings.Builder
+	text := v.Text
+	for {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			return "", errorf(v.Pos, "变量引用缺少 }: %s", text[start:])
+		}
+		name := text[start+2 : start+end]
+		value, ok := s.vars[name]
+		if !ok {
+			return "", errorf(v.Pos, "未定义的变量: %s", name)
+		}
+		b.WriteString(text[:start])
+		b.WriteString(value)
+		text = text[start+end+1:]
+	}
+	b.WriteString(text)
+	return strings.ReplaceAll(b.String(), string(escapedDollar), "$"), nil
+}
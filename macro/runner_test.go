@@ -0,0 +1,99 @@
+package macro
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pi-keyboard/act"
+)
+
+func newTestRunner() (*Runner, *act.VirtualDriver) {
+	driver := act.NewVirtualDriver(nil)
+	runner := NewRunner(driver)
+	runner.PressDuration = 0
+	runner.Gap = 0
+	return runner, driver
+}
+
+func TestRunStepLimit(t *testing.T) {
+	scripts := map[string]string{
+		"空循环嵌套": "repeat 10000 { repeat 10000 { repeat 10000 { } } }",
+		"赋值循环":  "repeat 10000 { repeat 10000 { set x \"y\" } }",
+	}
+	for name, src := range scripts {
+		t.Run(name, func(t *testing.T) {
+			script, err := Parse(src)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			runner, _ := newTestRunner()
+
+			start := time.Now()
+			result, err := runner.Run(context.Background(), script, nil)
+			if !errors.Is(err, ErrStepLimit) {
+				t.Fatalf("期望 ErrStepLimit，得到 %v", err)
+			}
+			if result.Steps != MaxSteps+1 {
+				t.Errorf("步数 = %d，期望 %d", result.Steps, MaxSteps+1)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("超过步数上限后耗时 %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunCancelEmptyLoop(t *testing.T) {
+	script, err := Parse("repeat 10000 { repeat 10000 { } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, _ := newTestRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := runner.Run(ctx, script, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，得到 %v", err)
+	}
+	if result.Steps != 0 {
+		t.Errorf("取消后仍执行了 %d 步", result.Steps)
+	}
+}
+
+func TestRunReleasesHeldKeys(t *testing.T) {
+	script, err := Parse("hold shift { press a; press $missing }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, driver := newTestRunner()
+
+	_, err = runner.Run(context.Background(), script, nil)
+	var macroErr *Error
+	if !errors.As(err, &macroErr) || macroErr.Line != 1 || macroErr.Column != 29 {
+		t.Fatalf("期望第 1 行第 29 列的错误，得到 %v", err)
+	}
+	if pressed := driver.PressedKeys(); len(pressed) != 0 {
+		t.Errorf("出错后仍按住 %v", pressed)
+	}
+	if text := driver.RenderedText(); text != "A" {
+		t.Errorf("主机看到 %q，期望 \"A\"", text)
+	}
+}
+
+func TestRunVariables(t *testing.T) {
+	script, err := Parse("set n = 2\nrepeat $n { type \"${word}\" }\ntype \"\\${word}\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, driver := newTestRunner()
+
+	if _, err := runner.Run(context.Background(), script, map[string]string{"word": "ab"}); err != nil {
+		t.Fatal(err)
+	}
+	if text := driver.RenderedText(); text != "abab${word}" {
+		t.Errorf("主机看到 %q", text)
+	}
+}
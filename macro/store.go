@@ -0,0 +1,252 @@
+package macro
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileExt 宏文件扩展名
+const FileExt = ".macro"
+
+// maxNameLen 宏名称的最大长度
+const maxNameLen = 64
+
+//go:embed defaults/*.macro
+var defaultMacros embed.FS
+
+// 宏库错误
+var (
+	ErrMacroNotFound = errors.New("宏不存在")
+	ErrBuiltinMacro  = errors.New("内置宏不能删除，可以用同名宏覆盖")
+	ErrReadOnlyStore = errors.New("未配置宏目录，宏库只读")
+)
+
+// DefaultMacros 返回内置的默认宏集合（登录、进入 BIOS、启动菜单等）
+func DefaultMacros() fs.FS {
+	sub, err := fs.Sub(defaultMacros, "defaults")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Info 宏的摘要信息
+type Info struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"` // 脚本开头的注释
+	Params      []string   `json:"params"`                // 运行时须提供的变量
+	Builtin     bool       `json:"builtin"`               // 来自内置宏集合（未被目录中的同名宏覆盖）
+	Overrides   bool       `json:"overrides,omitempty"`   // 目录中的宏覆盖了同名内置宏
+	ModTime     *time.Time `json:"mod_time,omitempty"`    // 目录中的宏的修改时间
+	Error       string     `json:"error,omitempty"`       // 脚本无法解析或当前驱动不支持其中的按键
+}
+
+// Macro 宏脚本及其信息
+type Macro struct {
+	Info
+	Script string `json:"script"`
+}
+
+// Store 宏库：目录中每个 <name>.macro 文件是一个宏，可叠加只读的内置宏集合
+//
+// 目录中的宏覆盖同名内置宏，删除后恢复内置版本。dir 为空时宏库只读。
+type Store struct {
+	dir      string
+	defaults fs.FS
+
+	mu sync.RWMutex
+}
+
+// NewStore 创建宏库，dir 不存在时自动创建；defaults 为 nil 时不使用内置宏
+func NewStore(dir string, defaults fs.FS) (*Store, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建宏目录失败: %v", err)
+		}
+	}
+	return &Store{dir: dir, defaults: defaults}, nil
+}
+
+// ValidName 宏名称由字母、数字、-、_ 组成，不超过 64 个字符
+func ValidName(name string) bool {
+	if name == "" || len(name) > maxNameLen {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r == '-', r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// List 返回所有宏的信息，按名称排序
+func (s *Store) List() ([]Info, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make(map[string]bool)
+	if s.defaults != nil {
+		builtin, err := fs.Glob(s.defaults, "*"+FileExt)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range builtin {
+			names[strings.TrimSuffix(file, FileExt)] = true
+		}
+	}
+	if s.dir != "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), FileExt)
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), FileExt) && ValidName(name) {
+				names[name] = true
+			}
+		}
+	}
+
+	infos := make([]Info, 0, len(names))
+	for name := range names {
+		m, err := s.get(name)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, m.Info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Get 读取宏，不存在时返回 ErrMacroNotFound
+func (s *Store) Get(name string) (*Macro, error) {
+	if !ValidName(name) {
+		return nil, ErrMacroNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(name)
+}
+
+// get 读取宏，目录中的宏优先于内置宏
+func (s *Store) get(name string) (*Macro, error) {
+	builtin := s.builtin(name)
+	if s.dir != "" {
+		path := filepath.Join(s.dir, name+FileExt)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			stat, _ := os.Stat(path)
+			m := newMacro(name, string(data))
+			m.Overrides = builtin != nil
+			if stat != nil {
+				modTime := stat.ModTime()
+				m.ModTime = &modTime
+			}
+			return m, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if builtin == nil {
+		return nil, ErrMacroNotFound
+	}
+	m := newMacro(name, string(builtin))
+	m.Builtin = true
+	return m, nil
+}
+
+// builtin 读取内置宏，不存在时返回 nil
+func (s *Store) builtin(name string) []byte {
+	if s.defaults == nil {
+		return nil
+	}
+	data, err := fs.ReadFile(s.defaults, name+FileExt)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Put 保存宏（调用方负责校验脚本），先写临时文件再重命名，避免写到一半的宏被读取
+func (s *Store) Put(name, script string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("无效的宏名称: %q（只能包含字母、数字、-、_）", name)
+	}
+	if s.dir == "" {
+		return ErrReadOnlyStore
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(script); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name+FileExt))
+}
+
+// Delete 删除目录中的宏；只有内置版本时返回 ErrBuiltinMacro
+func (s *Store) Delete(name string) error {
+	if !ValidName(name) {
+		return ErrMacroNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		err := os.Remove(filepath.Join(s.dir, name+FileExt))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if s.builtin(name) != nil {
+		return ErrBuiltinMacro
+	}
+	return ErrMacroNotFound
+}
+
+// newMacro 解析脚本信息：开头的注释作为描述，未赋值的变量作为参数
+func newMacro(name, script string) *Macro {
+	m := &Macro{Info: Info{Name: name, Params: []string{}}, Script: script}
+	var desc []string
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		desc = append(desc, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+	}
+	m.Description = strings.Join(desc, " ")
+
+	parsed, err := Parse(script)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	m.Params = parsed.Params()
+	return m
+}
@@ -0,0 +1,121 @@
+package macro
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "macros")
+	defaults := fstest.MapFS{
+		"login" + FileExt: {Data: []byte("# 内置登录\ntype \"${user}\\n\"\n")},
+	}
+	store, err := NewStore(dir, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func TestStoreOverrideBuiltin(t *testing.T) {
+	store, dir := newTestStore(t)
+
+	m, err := store.Get("login")
+	if err != nil || !m.Builtin || m.Overrides || m.Description != "内置登录" {
+		t.Fatalf("内置宏: %+v, %v", m, err)
+	}
+
+	// 目录中的同名宏覆盖内置宏
+	if err := store.Put("login", "# 自定义登录\npress enter\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "login"+FileExt)); err != nil {
+		t.Fatalf("宏文件未写入目录: %v", err)
+	}
+	m, err = store.Get("login")
+	if err != nil || m.Builtin || !m.Overrides || m.Description != "自定义登录" || m.ModTime == nil {
+		t.Fatalf("覆盖后的宏: %+v, %v", m, err)
+	}
+	infos, err := store.List()
+	if err != nil || len(infos) != 1 || infos[0].Name != "login" || !infos[0].Overrides {
+		t.Fatalf("List() = %+v, %v", infos, err)
+	}
+
+	// 删除目录中的宏后恢复内置版本，内置版本本身不能删除
+	if err := store.Delete("login"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := store.Get("login"); err != nil || !m.Builtin {
+		t.Fatalf("删除后未恢复内置宏: %+v, %v", m, err)
+	}
+	if err := store.Delete("login"); !errors.Is(err, ErrBuiltinMacro) {
+		t.Errorf("删除内置宏返回 %v，期望 ErrBuiltinMacro", err)
+	}
+	if err := store.Delete("nosuch"); !errors.Is(err, ErrMacroNotFound) {
+		t.Errorf("删除不存在的宏返回 %v，期望 ErrMacroNotFound", err)
+	}
+}
+
+func TestStoreReadOnly(t *testing.T) {
+	store, err := NewStore("", fstest.MapFS{"bios" + FileExt: {Data: []byte("press f2\n")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("bios", "press del\n"); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("只读宏库 Put 返回 %v，期望 ErrReadOnlyStore", err)
+	}
+	if m, err := store.Get("bios"); err != nil || m.Script != "press f2\n" {
+		t.Errorf("只读宏库 Get: %+v, %v", m, err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"login": true, "boot-menu_2": true,
+		"": false, "../x": false, "a/b": false, "a.macro": false, "登录": false,
+		string(make([]byte, maxNameLen+1)): false,
+	} {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v，期望 %v", name, got, want)
+		}
+	}
+
+	store, dir := newTestStore(t)
+	if err := store.Put("../x", "press a\n"); err == nil {
+		t.Error("Put(\"../x\") 未返回错误")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "x"+FileExt)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Put(\"../x\") 写到了宏目录之外: %v", err)
+	}
+	if _, err := store.Get("../x"); !errors.Is(err, ErrMacroNotFound) {
+		t.Errorf("Get(\"../x\") 返回 %v，期望 ErrMacroNotFound", err)
+	}
+}
+
+func TestStoreParams(t *testing.T) {
+	store, _ := newTestStore(t)
+	if err := store.Put("creds", "set host = \"pc\"\ntype \"${user}@${host}\\n\"\ntype \"${password}\\n\"\n"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Get("creds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按出现顺序列出，脚本中赋值的变量不是参数
+	if want := []string{"user", "password"}; !reflect.DeepEqual(m.Params, want) {
+		t.Errorf("Params = %v，期望 %v", m.Params, want)
+	}
+
+	// 无法解析的脚本仍可保存和读取，错误记录在信息中
+	if err := store.Put("broken", "repeat { }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := store.Get("broken"); err != nil || m.Error == "" || len(m.Params) != 0 {
+		t.Errorf("无法解析的宏: %+v, %v", m, err)
+	}
+}
@@ -5,23 +5,63 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"pi-keyboard/act"
+	"pi-keyboard/hid"
 	"pi-keyboard/logger"
+	"pi-keyboard/macro"
 	"time"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gadget":
+			if err := runGadgetCommand(os.Args[2:]); err != nil {
+				log.Fatalf("[GADGET] %v", err)
+			}
+			return
+		case "host-sim":
+			if err := runHostSimCommand(os.Args[2:]); err != nil {
+				log.Fatalf("[HOST-SIM] %v", err)
+			}
+			return
+		case "replay":
+			if err := runReplayCommand(os.Args[2:]); err != nil {
+				log.Fatalf("[REPLAY] %v", err)
+			}
+			return
+		}
+	}
+
 	// 命令行参数定义
 	var (
-		port       = flag.String("port", "8081", "服务端口")
-		driverType = flag.String("driver", "", "强制指定驱动类型 (linux_otg, macos_automation)")
-		outputFile = flag.String("output", "", "Linux OTG 输出文件路径")
+		port         = flag.String("port", "8081", "服务端口")
+		driverType   = flag.String("driver", "", "强制指定驱动类型 (linux_otg, macos_automation, virtual)")
+		outputFile   = flag.String("output", "", "Linux OTG 输出文件路径")
+		mouseFile    = flag.String("mouse-output", "", "Linux OTG 鼠标输出文件路径，默认按 -hid-features 推算（与 gadget up 创建的设备一致）")
+		consumerFile = flag.String("consumer-output", "", "Linux OTG 媒体键（Consumer Control）输出文件路径，默认按 -hid-features 推算（与 gadget up 创建的设备一致）")
+		fileSink     = flag.Bool("file-sink", false, "允许 -output 指向普通文件或 FIFO（调试用，默认只接受字符设备）")
+		writeTimeout = flag.Duration("write-timeout", act.DefaultHIDWriteTimeout, "HID 报文写入期限，主机休眠或断开时超时返回 504")
+		layoutName   = flag.String("layout", act.DefaultLayoutName, "主机键盘布局 (us, uk, de, fr, jp) 或布局文件路径 (.json)")
+		layoutDir    = flag.String("layout-dir", "", "自定义布局文件目录，加载其中所有 .json 布局")
+		unicode      = flag.String("unicode", "", "布局无法输入的字符的输入策略 (linux, windows, macos, pinyin)，默认不启用")
+		pinyinDict   = flag.String("pinyin-dict", "", "拼音词典文件路径，每行「汉字 拼音」")
+		capsLock     = flag.String("caps-lock", act.CapsLockShift, "主机 Caps Lock 开启时文本输入的补偿方式 (shift, toggle, ignore)")
+		hidFeatures  = flag.String("hid-features", hid.DefaultFeatures().String(), "gadget 的 HID 特性 (boot, nkro, leds, consumer, mouse)，须与 gadget up 一致")
+		udcRoot      = flag.String("udc-root", act.DefaultUDCRoot, "UDC sysfs 目录，用于检测主机连接状态")
+		udc          = flag.String("udc", "", "监视的 UDC 名称，默认使用第一个 UDC")
+		faultSpec    = flag.String("fault", "", "故障注入（测试用），如 press:latency=200ms,error=0.1;type:partial=0.2")
+		protocolFile = flag.String("hid-protocol-file", "", "外部提供的 SET_PROTOCOL 协议文件 (0/boot, 1/report)，NKRO 模式据此回退到 boot 报文；标准 f_hid 不提供该文件，未指定时需通过 /protocol 手动切换")
+		macroDir     = flag.String("macro-dir", "macros", "宏库目录，每个 <name>.macro 文件是一个宏，为空时宏库只读")
+		noMacros     = flag.Bool("no-default-macros", false, "不加载内置的默认宏（登录、进入 BIOS 等）")
 
 		// 日志配置
 		enableHTTPLog   = flag.Bool("log", true, "是否启用HTTP日志")
@@ -41,6 +81,9 @@ func main() {
 		fmt.Println()
 		fmt.Println("用法:")
 		fmt.Printf("  %s [选项]\n", os.Args[0])
+		fmt.Printf("  %s gadget up|down|status [选项]   管理 USB gadget\n", os.Args[0])
+		fmt.Printf("  %s host-sim -input <文件或 FIFO>   模拟主机，解码报文\n", os.Args[0])
+		fmt.Printf("  %s replay [选项] <记录文件>        回放按键记录\n", os.Args[0])
 		fmt.Println()
 		fmt.Println("选项:")
 		flag.PrintDefaults()
@@ -49,6 +92,7 @@ func main() {
 		fmt.Printf("  %s -port 8081 -log-output file -log-file ./logs/api.log\n", os.Args[0])
 		fmt.Printf("  %s -driver macos_automation -log-req-body\n", os.Args[0])
 		fmt.Printf("  %s -log false\n", os.Args[0])
+		fmt.Printf("  sudo %s gadget up\n", os.Args[0])
 		return
 	}
 
@@ -58,12 +102,85 @@ func main() {
 	factory := act.NewDriverFactory()
 	log.Printf("驱动工厂创建成功")
 
+	// 加载键盘布局
+	if *layoutDir != "" {
+		loaded, err := act.LoadLayoutDir(*layoutDir)
+		if err != nil {
+			log.Fatalf("加载布局目录失败: %v", err)
+		}
+		log.Printf("从 %s 加载了 %d 个布局", *layoutDir, len(loaded))
+	}
+	layout, err := act.ResolveLayout(*layoutName)
+	if err != nil {
+		log.Fatalf("加载键盘布局失败: %v", err)
+	}
+	log.Printf("主机键盘布局: %s (可用: %v)", layout.Name, act.LayoutNames())
+
+	// Unicode 输入策略
+	if *pinyinDict != "" {
+		count, err := act.LoadPinyinDict(*pinyinDict)
+		if err != nil {
+			log.Fatalf("加载拼音词典失败: %v", err)
+		}
+		log.Printf("从 %s 加载了 %d 个拼音条目", *pinyinDict, count)
+	}
+	unicodeStrategy, err := act.GetUnicodeStrategy(*unicode)
+	if err != nil {
+		log.Fatalf("Unicode 输入策略无效: %v", err)
+	}
+	if unicodeStrategy != nil {
+		log.Printf("Unicode 输入策略: %s", unicodeStrategy.Name())
+	}
+
+	// HID 特性
+	features, err := hid.ParseFeatures(*hidFeatures)
+	if err != nil {
+		log.Fatalf("HID 特性无效: %v", err)
+	}
+	log.Printf("HID 特性: %s", features)
+	if features.NKRO && !features.BootKeyboard {
+		log.Printf("HID 特性未包含 %s，主机无法切换到 boot 协议，BIOS/UEFI 中键盘不可用", hid.FeatureBootKeyboard)
+	}
+
 	// 获取配置选项
-	var options []act.DriverOption
+	options := []act.DriverOption{
+		act.WithLayout(layout.Name),
+		act.WithUnicodeStrategy(*unicode),
+		act.WithCapsLockMode(*capsLock),
+		act.WithHIDFeatures(features),
+	}
 	if *outputFile != "" {
 		options = append(options, act.WithOutputFile(*outputFile))
 		log.Printf("配置输出文件: %s", *outputFile)
 	}
+	if *mouseFile != "" {
+		options = append(options, act.WithMouseFile(*mouseFile))
+		log.Printf("配置鼠标输出文件: %s", *mouseFile)
+	}
+	if *consumerFile != "" {
+		options = append(options, act.WithConsumerFile(*consumerFile))
+		log.Printf("配置媒体键输出文件: %s", *consumerFile)
+	}
+	if *writeTimeout > 0 {
+		options = append(options, act.WithWriteTimeout(*writeTimeout))
+	}
+	if *fileSink {
+		options = append(options, act.WithFileSink(true))
+		log.Printf("启用文件输出模式")
+	}
+	options = append(options, act.WithUDCRoot(*udcRoot), act.WithUDC(*udc))
+	if *protocolFile != "" {
+		options = append(options, act.WithProtocolFile(*protocolFile))
+		log.Printf("HID 协议文件: %s", *protocolFile)
+	}
+	if *faultSpec != "" {
+		plan, err := act.ParseFaultPlan(*faultSpec)
+		if err != nil {
+			log.Fatalf("故障注入配置无效: %v", err)
+		}
+		options = append(options, act.WithFaultInjection(plan))
+		log.Printf("故障注入: %s", *faultSpec)
+	}
 	if *driverType != "" {
 		options = append(options, act.WithDriverType(*driverType))
 		log.Printf("强制指定驱动类型: %s", *driverType)
@@ -77,8 +194,22 @@ func main() {
 	}
 	defer driver.Close()
 
+	// 创建鼠标驱动（可选，不支持的平台只提供键盘功能）
+	var mouse *act.Mouse
+	if mouseDriver, err := factory.CreateMouseDriver(options...); err != nil {
+		log.Printf("鼠标驱动不可用: %v", err)
+	} else {
+		defer mouseDriver.Close()
+		mouse = act.NewMouse(mouseDriver)
+	}
+
 	// 创建键盘服务
 	keyboard := act.NewKeyboard(driver)
+	keyboard.SetLayout(layout)
+	keyboard.SetUnicodeStrategy(unicodeStrategy)
+	if err := keyboard.SetCapsLockMode(*capsLock); err != nil {
+		log.Fatalf("Caps Lock 补偿方式无效: %v", err)
+	}
 	log.Printf("键盘服务创建成功")
 
 	// 输出驱动信息
@@ -104,7 +235,7 @@ func main() {
 	// 输出日志配置信息
 	log.Printf("HTTP日志配置: 启用=%v, 输出=%s, 文件=%s",
 		logConfig.EnableHTTPLog, logConfig.Output, logConfig.LogFile)
-	log.Printf("记录的API: /press, /press-sync, /actions, /type")
+	log.Printf("记录的API: /press, /press-sync, /actions, /type, /macro/run, /macros")
 
 	// API 接口注册 - 有选择性地使用日志中间件
 	// 核心功能API - 记录日志
@@ -113,15 +244,47 @@ func main() {
 	http.Handle("/actions", httpLogger.Middleware(http.HandlerFunc(keyboard.ActionsHandler)))
 	http.Handle("/type", httpLogger.Middleware(http.HandlerFunc(keyboard.TypeHandler)))
 
+	// 宏脚本接口
+	var defaultMacros fs.FS
+	if !*noMacros {
+		defaultMacros = macro.DefaultMacros()
+	}
+	macroStore, err := macro.NewStore(*macroDir, defaultMacros)
+	if err != nil {
+		log.Fatalf("创建宏库失败: %v", err)
+	}
+	macros := macro.NewService(keyboard, macroStore)
+	http.Handle("/macro/run", httpLogger.Middleware(http.HandlerFunc(macros.RunHandler)))
+	http.Handle("/macros", httpLogger.Middleware(http.HandlerFunc(macros.MacrosHandler)))
+	http.Handle("/macros/", httpLogger.Middleware(http.HandlerFunc(macros.MacrosHandler)))
+
 	// 新增 keydown/keyup 接口
 	http.Handle("/keydown", httpLogger.Middleware(http.HandlerFunc(keyboard.KeyDownHandler)))
 	http.Handle("/keyup", httpLogger.Middleware(http.HandlerFunc(keyboard.KeyUpHandler)))
 
+	// 鼠标接口
+	if mouse != nil {
+		http.Handle("/mouse/move", httpLogger.Middleware(http.HandlerFunc(mouse.MoveHandler)))
+		http.Handle("/mouse/click", httpLogger.Middleware(http.HandlerFunc(mouse.ClickHandler)))
+		http.Handle("/mouse/down", httpLogger.Middleware(http.HandlerFunc(mouse.ButtonDownHandler)))
+		http.Handle("/mouse/up", httpLogger.Middleware(http.HandlerFunc(mouse.ButtonUpHandler)))
+		http.Handle("/mouse/scroll", httpLogger.Middleware(http.HandlerFunc(mouse.ScrollHandler)))
+	}
+
 	// 新增记录按键接口
 	http.HandleFunc("/api/record_keys", keyboard.RecordKeysHandler)
+	http.Handle("/api/replay", httpLogger.Middleware(http.HandlerFunc(keyboard.ReplayHandler)))
 
 	// 统计接口 - 不记录日志（避免过多日志）
 	http.HandleFunc("/stats", keyboard.StatsHandler)
+	http.HandleFunc("/health", keyboard.HealthHandler)
+	http.HandleFunc("/leds", keyboard.LEDsHandler)
+	http.HandleFunc("/keys", keyboard.KeysHandler)
+	http.HandleFunc("/protocol", keyboard.ProtocolHandler)
+	http.HandleFunc("/host", keyboard.HostHandler)
+	http.HandleFunc("/host/events", keyboard.HostEventsHandler)
+	http.HandleFunc("/host/wait", keyboard.HostWaitHandler)
+	http.HandleFunc("/virtual", keyboard.VirtualHandler)
 
 	// ========== 新增：主机名和git信息 ==========
 	hostname, _ := os.Hostname()
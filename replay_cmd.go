@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"pi-keyboard/act"
+	"pi-keyboard/hid"
+	"time"
+)
+
+// runReplayCommand 处理 replay 子命令：按记录的时间线通过驱动重放 key_record_<ts>.csv
+func runReplayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	driverType := flags.String("driver", "", "驱动类型 (linux_otg, macos_automation, virtual)，默认自动检测")
+	outputFile := flags.String("output", "", "Linux OTG 输出文件路径")
+	consumerFile := flags.String("consumer-output", "", "Linux OTG 媒体键输出文件路径，默认按 -hid-features 推算（与 gadget up 创建的设备一致）")
+	fileSink := flags.Bool("file-sink", false, "允许 -output 指向普通文件或 FIFO（调试用）")
+	features := flags.String("hid-features", hid.DefaultFeatures().String(), "HID 特性，须与 gadget up 一致")
+	speed := flags.Float64("speed", 1, "速度倍数，2 表示两倍速")
+	maxGap := flags.Duration("max-gap", 0, "超过该值的间隔压缩为该值（按速度换算后），0 表示不压缩")
+	flags.Usage = func() {
+		fmt.Println("用法:")
+		fmt.Printf("  %s replay [选项] <key_record_xxx.csv>\n", os.Args[0])
+		fmt.Println()
+		fmt.Println("示例:")
+		fmt.Printf("  %s replay -speed 2 -max-gap 1s key_record_1700000000.csv\n", os.Args[0])
+		fmt.Printf("  %s replay -driver virtual key_record_1700000000.csv   # 只检查记录和时间线\n", os.Args[0])
+		fmt.Println()
+		fmt.Println("Ctrl+C 停止回放并释放仍按住的按键。")
+		fmt.Println()
+		fmt.Println("选项:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("需要一个记录文件")
+	}
+	opts := act.ReplayOptions{Speed: *speed, MaxGap: *maxGap}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	events, err := act.LoadRecord(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("记录为空: %s", flags.Arg(0))
+	}
+
+	parsed, err := hid.ParseFeatures(*features)
+	if err != nil {
+		return err
+	}
+	options := []act.DriverOption{act.WithHIDFeatures(parsed)}
+	if *driverType != "" {
+		options = append(options, act.WithDriverType(*driverType))
+	}
+	if *outputFile != "" {
+		options = append(options, act.WithOutputFile(*outputFile))
+	}
+	if *consumerFile != "" {
+		options = append(options, act.WithConsumerFile(*consumerFile))
+	}
+	if *fileSink {
+		options = append(options, act.WithFileSink(true))
+	}
+	driver, err := act.NewDriverFactory().CreateDriver(options...)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+	if err := act.ValidateRecord(events, driver); err != nil {
+		return err
+	}
+
+	// Ctrl+C 停止回放
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	offsets := act.ReplaySchedule(events, opts)
+	fmt.Fprintf(os.Stderr, "[REPLAY] %s: %d个边沿，原始时长 %v，计划时长 %v（驱动 %s）\n",
+		flags.Arg(0), len(events), events[len(events)-1].Time.Sub(events[0].Time).Round(time.Millisecond),
+		offsets[len(offsets)-1].Round(time.Millisecond), driver.GetDriverType())
+
+	result, err := act.Replay(ctx, driver, events, opts)
+	fmt.Fprintf(os.Stderr, "[REPLAY] 已回放 %d/%d个边沿，耗时 %v，最大延迟 %v\n",
+		result.Events, result.Total, result.Duration.Round(time.Millisecond), result.MaxLag)
+	if len(result.Released) > 0 {
+		fmt.Fprintf(os.Stderr, "[REPLAY] 结束时释放仍按住的按键: %v\n", result.Released)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("回放已取消")
+	}
+	return err
+}
@@ -18,14 +18,14 @@ func main() {
 	available := factory.GetAvailableDrivers()
 	fmt.Printf("可用驱动: %v\n", available)
 
-	if len(available) == 0 {
-		log.Fatal("当前平台没有可用的键盘驱动")
-	}
-
-	// 创建驱动
+	// 创建驱动，没有硬件时使用虚拟驱动
 	driver, err := factory.CreateDriver()
 	if err != nil {
-		log.Fatalf("创建驱动失败: %v", err)
+		fmt.Printf("创建驱动失败: %v，改用虚拟驱动\n", err)
+		driver, err = factory.CreateDriver(act.WithDriverType(act.DriverTypeVirtual))
+		if err != nil {
+			log.Fatalf("创建虚拟驱动失败: %v", err)
+		}
 	}
 	defer driver.Close()
 
@@ -45,6 +45,8 @@ func main() {
 		testMacOS(driver)
 	case act.DriverTypeLinuxOTG:
 		testLinuxOTG(driver)
+	case act.DriverTypeVirtual:
+		testVirtual(driver)
 	default:
 		fmt.Printf("未知驱动类型: %s\n", driver.GetDriverType())
 	}
@@ -126,3 +128,23 @@ func testLinuxOTG(driver act.KeyboardDriver) {
 
 	fmt.Println("Linux OTG 驱动测试完成")
 }
+
+func testVirtual(driver act.KeyboardDriver) {
+	fmt.Println("\n=== 虚拟驱动测试 ===")
+
+	driver.KeyDown("shift")
+	driver.Press("h", 50*time.Millisecond)
+	driver.KeyUp("shift")
+	if err := driver.Type("ello world"); err != nil {
+		fmt.Printf("文本输入测试失败: %v\n", err)
+		return
+	}
+	driver.Press("enter", 50*time.Millisecond)
+
+	recorder := driver.(act.EventRecorder)
+	for _, event := range recorder.Events(0) {
+		fmt.Printf("  #%d %s %s%s\n", event.Seq, event.Action, event.Key, event.Text)
+	}
+	fmt.Printf("主机会看到: %q\n", recorder.RenderedText())
+	fmt.Println("虚拟驱动测试完成")
+}
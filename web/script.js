@@ -31,6 +31,8 @@ class PiKeyboard {
         this.recordFileNameSpan = document.getElementById('recordFileName');
         this.isRecording = false;
         this.recordFileName = '';
+        this.macroList = document.getElementById('macroList');
+        this.refreshMacrosButton = document.getElementById('refreshMacros');
         
         // 添加日志系统
         this.enableDebugLog();
@@ -47,7 +49,7 @@ class PiKeyboard {
         this.log(`🔗 当前页面URL: ${window.location.href}`);
         this.log(`🌍 网络状态: ${navigator.onLine ? '在线' : '离线'}`);
         this.log(`🕐 页面加载时间: ${new Date().toLocaleString()}`);
-        this.log('⚡ 并发处理: 无队列等待，每个请求独立处理');
+        this.log('⚡ 单键并发处理，批量操作和文本输入按顺序执行');
         
         // 监听网络状态
         window.addEventListener('online', () => {
@@ -213,6 +215,12 @@ class PiKeyboard {
             }
         });
         
+        // 绑定宏刷新按钮事件并加载宏列表
+        this.refreshMacrosButton.addEventListener('click', () => {
+            this.loadMacros();
+        });
+        this.loadMacros();
+        
         // 绑定文本输入框回车事件
         this.textInput.addEventListener('keypress', (e) => {
             if (e.key === 'Enter' && (e.ctrlKey || e.metaKey)) {
@@ -672,6 +680,75 @@ class PiKeyboard {
         }
     }
     
+    async loadMacros() {
+        try {
+            const resp = await fetch(`${this.apiBase}/macros`);
+            if (!resp.ok) {
+                throw new Error(await resp.text() || `HTTP ${resp.status}`);
+            }
+            const data = await resp.json();
+            this.macroList.textContent = '';
+            if (data.macros.length === 0) {
+                this.macroList.textContent = '宏库为空，可通过 PUT /macros/{name} 添加';
+                return;
+            }
+            data.macros.forEach((info) => {
+                const button = document.createElement('button');
+                button.className = 'macro-btn';
+                button.textContent = info.name;
+                button.title = info.error || info.description || info.name;
+                button.disabled = Boolean(info.error);
+                button.addEventListener('click', () => this.runMacro(info, button));
+                this.macroList.appendChild(button);
+            });
+            this.log(`📜 已加载 ${data.macros.length} 个宏`);
+        } catch (e) {
+            this.macroList.textContent = '宏列表加载失败';
+            this.log('❌ 加载宏失败: ' + e.message, 'error');
+        }
+    }
+
+    async runMacro(info, button) {
+        // 逐个询问宏参数，取消时不执行
+        const vars = {};
+        for (const param of info.params) {
+            const value = window.prompt(`宏 ${info.name} 的参数 ${param}:`);
+            if (value === null) {
+                return;
+            }
+            vars[param] = value;
+        }
+
+        this.log(`📜 执行宏: ${info.name}`);
+        this.updateStatus(`正在执行宏 ${info.name}...`, 'loading');
+        button.disabled = true;
+        try {
+            const resp = await fetch(`${this.apiBase}/macros/${encodeURIComponent(info.name)}/run`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ vars })
+            });
+            const text = await resp.text();
+            let result = {};
+            try {
+                result = JSON.parse(text);
+            } catch (e) {
+                result = { error: text };
+            }
+            if (!resp.ok) {
+                const where = result.line ? ` (第 ${result.line} 行第 ${result.column} 列)` : '';
+                throw new Error((result.error || `HTTP ${resp.status}`) + where);
+            }
+            this.log(`✅ 宏 ${info.name} 完成: ${result.steps} 步, ${result.duration_ms}ms`, 'success');
+            this.updateStatus(`宏 ${info.name} 已完成 (${result.duration_ms}ms)`, 'success');
+        } catch (e) {
+            this.log(`❌ 宏 ${info.name} 失败: ${e.message}`, 'error');
+            this.updateStatus(`宏 ${info.name} 失败: ${e.message}`, 'error');
+        } finally {
+            button.disabled = false;
+        }
+    }
+    
     updateStatus(message, type = 'success') {
         this.statusElement.textContent = message;
         this.statusElement.className = `status-display ${type}`;